@@ -2,6 +2,7 @@ package kit
 
 import (
 	"context"
+
 	"github.com/mishudark/kit/log"
 )
 
@@ -22,8 +23,25 @@ func NewLogErrorHandler(logger log.Logger) *LogErrorHandler {
 	}
 }
 
+// Handle logs the error through the logger of the handler, along with the
+// request id, method and path found in the context, see
+// PopulateRequestContext. The logger carried by the context is not used, so
+// a nop logger keeps the errors silent even with RequestLogger.
 func (h *LogErrorHandler) Handle(ctx context.Context, err error) {
-	h.logger.Log("err", err)
+	keyvals := make([]interface{}, 0, 8)
+	for _, f := range []struct {
+		name string
+		key  contextKey
+	}{
+		{"request_id", ContextKeyRequestXRequestID},
+		{"method", ContextKeyRequestMethod},
+		{"path", ContextKeyRequestPath},
+	} {
+		if v := contextString(ctx, f.key, ""); v != "" {
+			keyvals = append(keyvals, f.name, v)
+		}
+	}
+	h.logger.Log(append(keyvals, "err", err)...)
 }
 
 // The ErrorHandlerFunc type is an adapter to allow the use of
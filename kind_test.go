@@ -0,0 +1,66 @@
+package kit_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mishudark/errors"
+	"github.com/mishudark/kit"
+)
+
+func TestKindErrorEncoder(t *testing.T) {
+	tc := [...]struct {
+		name string
+		err  error
+		code int
+	}{
+		{
+			name: "unmarshal",
+			err:  errors.E(errors.New("unexpected EOF"), "can not unmarshal request", errors.Unmarshal),
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "permission",
+			err:  errors.E(errors.New("nope"), errors.Permission),
+			code: http.StatusForbidden,
+		},
+		{
+			name: "wrapped not exist",
+			err:  fmt.Errorf("loading order: %w", errors.E(errors.New("no rows"), errors.NotExist)),
+			code: http.StatusNotFound,
+		},
+		{
+			name: "unmapped kind",
+			err:  errors.E(errors.New("disk full"), errors.IO),
+			code: http.StatusInternalServerError,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			kit.KindErrorEncoder(context.Background(), tt.err, rec)
+			if want, have := tt.code, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestNewKindErrorEncoder(t *testing.T) {
+	enc := kit.NewKindErrorEncoder(kit.KindStatusCodes{errors.IO: http.StatusBadGateway})
+
+	rec := httptest.NewRecorder()
+	enc(context.Background(), errors.E(errors.New("upstream down"), errors.IO), rec)
+	if want, have := http.StatusBadGateway, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,219 @@
+package log
+
+import (
+	stdcontext "context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultAsyncBufferSize is the number of events buffered by an AsyncLogger,
+// unless set with AsyncBufferSize.
+const DefaultAsyncBufferSize = 1024
+
+// ErrAsyncLoggerClosed is returned by the Log method of a closed AsyncLogger.
+var ErrAsyncLoggerClosed = errors.New("log: async logger closed")
+
+// OverflowPolicy decides what an AsyncLogger does with a new event when its
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Log wait until there is room in the buffer, so no
+	// event is lost.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the new event.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest buffered event to make room for
+	// the new one.
+	OverflowDropOldest
+)
+
+// AsyncOption sets an optional parameter for an AsyncLogger.
+type AsyncOption func(*AsyncLogger)
+
+// AsyncBufferSize sets the number of events buffered before the overflow
+// policy applies. By default, DefaultAsyncBufferSize events are buffered.
+func AsyncBufferSize(n int) AsyncOption {
+	return func(l *AsyncLogger) {
+		if n > 0 {
+			l.buf = make([][]interface{}, n)
+		}
+	}
+}
+
+// AsyncOverflow sets the policy applied when the buffer is full. By default,
+// OverflowBlock is used.
+func AsyncOverflow(p OverflowPolicy) AsyncOption {
+	return func(l *AsyncLogger) { l.policy = p }
+}
+
+// AsyncLogger buffers the log events and passes them on to another logger
+// from a single goroutine, so slow writers don't hold up the callers. It's
+// safe for concurrent use by multiple goroutines.
+//
+// Valuers are bound once the event reaches the wrapped logger, so contextual
+// loggers must wrap the AsyncLogger, not the other way around, to log the
+// time of the call:
+//
+//	async := log.NewAsyncLogger(log.NewJSONLogger(w))
+//	defer async.Close()
+//	logger := log.With(async, "ts", log.DefaultTimestampUTC)
+//
+// Errors returned by the wrapped logger are ignored.
+type AsyncLogger struct {
+	logger  Logger
+	policy  OverflowPolicy
+	dropped uint64 // accessed atomically
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buf      [][]interface{} // ring buffer of the pending events
+	head     int             // index of the oldest event
+	n        int             // number of buffered events
+	busy     bool            // an event is being logged
+	closed   bool
+	waiters  []chan struct{} // closed once the buffer is drained
+	done     chan struct{}
+}
+
+// NewAsyncLogger returns an AsyncLogger passing the events on to logger, its
+// Close method must be called to log the buffered events and release the
+// goroutine.
+func NewAsyncLogger(logger Logger, options ...AsyncOption) *AsyncLogger {
+	l := &AsyncLogger{
+		logger: logger,
+		buf:    make([][]interface{}, DefaultAsyncBufferSize),
+		done:   make(chan struct{}),
+	}
+	l.notEmpty = sync.NewCond(&l.mu)
+	l.notFull = sync.NewCond(&l.mu)
+
+	for _, option := range options {
+		option(l)
+	}
+
+	go l.run()
+	return l
+}
+
+// Log implements the Logger interface by buffering a copy of keyvals, so the
+// caller may reuse the slice. The event is discarded, without error, when the
+// buffer is full and the policy drops events.
+func (l *AsyncLogger) Log(keyvals ...interface{}) error {
+	event := make([]interface{}, len(keyvals))
+	copy(event, keyvals)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.n == len(l.buf) && l.policy == OverflowBlock && !l.closed {
+		l.notFull.Wait()
+	}
+	if l.closed {
+		return ErrAsyncLoggerClosed
+	}
+
+	if l.n == len(l.buf) {
+		atomic.AddUint64(&l.dropped, 1)
+		if l.policy == OverflowDropNewest {
+			return nil
+		}
+		l.buf[l.head] = nil
+		l.head = (l.head + 1) % len(l.buf)
+		l.n--
+	}
+
+	l.buf[(l.head+l.n)%len(l.buf)] = event
+	l.n++
+	l.notEmpty.Signal()
+	return nil
+}
+
+// Dropped returns the number of events discarded because the buffer was
+// full.
+func (l *AsyncLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Flush waits until the buffer is drained and the last event is logged, or
+// until ctx is done, in that case the error of ctx is returned.
+func (l *AsyncLogger) Flush(ctx stdcontext.Context) error {
+	l.mu.Lock()
+	if l.n == 0 && !l.busy {
+		l.mu.Unlock()
+		return nil
+	}
+	drained := make(chan struct{})
+	l.waiters = append(l.waiters, drained)
+	l.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close logs the buffered events and stops the goroutine. Once closed, Log
+// returns ErrAsyncLoggerClosed. Use Flush beforehand to bound the time spent
+// logging the buffered events.
+func (l *AsyncLogger) Close() error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		l.notEmpty.Broadcast()
+		l.notFull.Broadcast()
+	}
+	l.mu.Unlock()
+
+	<-l.done
+	return nil
+}
+
+// run logs the buffered events, one at a time, until the logger is closed
+// and drained.
+func (l *AsyncLogger) run() {
+	defer close(l.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		for l.n == 0 && !l.closed {
+			l.notEmpty.Wait()
+		}
+		if l.n == 0 {
+			l.notifyDrained()
+			return
+		}
+
+		event := l.buf[l.head]
+		l.buf[l.head] = nil
+		l.head = (l.head + 1) % len(l.buf)
+		l.n--
+		l.busy = true
+		l.notFull.Signal()
+		l.mu.Unlock()
+
+		l.logger.Log(event...)
+
+		l.mu.Lock()
+		l.busy = false
+		if l.n == 0 {
+			l.notifyDrained()
+		}
+	}
+}
+
+// notifyDrained releases the Flush callers, l.mu must be held.
+func (l *AsyncLogger) notifyDrained() {
+	for _, drained := range l.waiters {
+		close(drained)
+	}
+	l.waiters = nil
+}
@@ -0,0 +1,152 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit/log"
+)
+
+// gateLogger logs to a logfmt logger once the gate is open, after reporting
+// the first event through started.
+type gateLogger struct {
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+	buf     bytes.Buffer
+}
+
+func newGateLogger() *gateLogger {
+	return &gateLogger{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (l *gateLogger) Log(keyvals ...interface{}) error {
+	l.once.Do(func() { close(l.started) })
+	<-l.gate
+	return log.NewLogfmtLogger(&l.buf).Log(keyvals...)
+}
+
+func TestAsyncLoggerOverflow(t *testing.T) {
+	tc := []struct {
+		name    string
+		policy  log.OverflowPolicy
+		dropped uint64
+		want    string
+	}{
+		{
+			name:    "drop newest",
+			policy:  log.OverflowDropNewest,
+			dropped: 2,
+			want:    "n=0\nn=1\nn=2\n",
+		},
+		{
+			name:    "drop oldest",
+			policy:  log.OverflowDropOldest,
+			dropped: 2,
+			want:    "n=0\nn=3\nn=4\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			gl := newGateLogger()
+			logger := log.NewAsyncLogger(gl, log.AsyncBufferSize(2), log.AsyncOverflow(tt.policy))
+
+			// the first event holds the goroutine, the next ones fill the buffer
+			logger.Log("n", 0)
+			<-gl.started
+			for i := 1; i < 5; i++ {
+				if err := logger.Log("n", i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			close(gl.gate)
+			logger.Close()
+
+			if want, have := tt.dropped, logger.Dropped(); want != have {
+				t.Errorf("dropped: want %d, have %d", want, have)
+			}
+			if want, have := tt.want, gl.buf.String(); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestAsyncLoggerBlock(t *testing.T) {
+	gl := newGateLogger()
+	logger := log.NewAsyncLogger(gl, log.AsyncBufferSize(1))
+
+	logger.Log("n", 0)
+	<-gl.started
+	logger.Log("n", 1)
+
+	logged := make(chan struct{})
+	go func() {
+		logger.Log("n", 2)
+		close(logged)
+	}()
+
+	select {
+	case <-logged:
+		t.Fatal("Log returned with a full buffer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(gl.gate)
+	<-logged
+	if err := logger.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "n=0\nn=1\nn=2\n", gl.buf.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := uint64(0), logger.Dropped(); want != have {
+		t.Errorf("dropped: want %d, have %d", want, have)
+	}
+	logger.Close()
+}
+
+func TestAsyncLoggerFlushTimeout(t *testing.T) {
+	gl := newGateLogger()
+	logger := log.NewAsyncLogger(gl)
+	defer logger.Close()
+	defer close(gl.gate)
+
+	logger.Log("n", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if want, have := context.DeadlineExceeded, logger.Flush(ctx); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestAsyncLoggerCopiesKeyvals(t *testing.T) {
+	gl := newGateLogger()
+	logger := log.NewAsyncLogger(gl)
+
+	keyvals := []interface{}{"k", "v"}
+	logger.Log(keyvals...)
+	keyvals[1] = "changed"
+	close(gl.gate)
+	logger.Close()
+
+	if want, have := "k=v\n", gl.buf.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := log.ErrAsyncLoggerClosed, logger.Log("k", "v"); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestAsyncLoggerConcurrency(t *testing.T) {
+	t.Parallel()
+	logger := log.NewAsyncLogger(log.NewLogfmtLogger(&bytes.Buffer{}), log.AsyncBufferSize(8))
+	testConcurrency(t, logger, 1000)
+	logger.Close()
+}
@@ -0,0 +1,18 @@
+package log
+
+import stdcontext "context"
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, so the functions handling
+// a request are able to log with the values bound to it, such as the request
+// id.
+func NewContext(ctx stdcontext.Context, logger Logger) stdcontext.Context {
+	return stdcontext.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, if any.
+func FromContext(ctx stdcontext.Context) (Logger, bool) {
+	logger, ok := ctx.Value(loggerKey{}).(Logger)
+	return logger, ok
+}
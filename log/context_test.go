@@ -0,0 +1,28 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/mishudark/kit/log"
+)
+
+func TestNewContext(t *testing.T) {
+	if _, ok := log.FromContext(context.Background()); ok {
+		t.Error("want no logger in an empty context")
+	}
+
+	var buf bytes.Buffer
+	ctx := log.NewContext(context.Background(), log.With(log.NewLogfmtLogger(&buf), "request_id", "abc"))
+
+	logger, ok := log.FromContext(ctx)
+	if !ok {
+		t.Fatal("want a logger in the context, have none")
+	}
+	logger.Log("msg", "hello")
+
+	if want, have := "request_id=abc msg=hello\n", buf.String(); want != have {
+		t.Errorf("want %#v, have %#v", want, have)
+	}
+}
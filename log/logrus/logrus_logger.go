@@ -7,12 +7,14 @@ import (
 	"fmt"
 
 	"github.com/mishudark/kit/log"
+	"github.com/mishudark/kit/log/level"
 	"github.com/sirupsen/logrus"
 )
 
 type Logger struct {
-	field logrus.FieldLogger
-	level logrus.Level
+	field    logrus.FieldLogger
+	level    logrus.Level
+	levelKey bool
 }
 
 type Option func(*Logger)
@@ -40,17 +42,31 @@ func WithLevel(level logrus.Level) Option {
 	}
 }
 
+// WithLevelKey configures a logrus logger to log each event at the level
+// set with the log/level package, the level key is not added to the fields.
+// Events without a level are logged at the level set with WithLevel.
+func WithLevelKey() Option {
+	return func(c *Logger) {
+		c.levelKey = true
+	}
+}
+
 func (l Logger) Log(keyvals ...interface{}) error {
+	lvl := l.level
 	fields := logrus.Fields{}
 	for i := 0; i < len(keyvals); i += 2 {
 		if i+1 < len(keyvals) {
+			if v, ok := keyvals[i+1].(level.Value); ok && l.levelKey && keyvals[i] == level.Key() {
+				lvl = logrusLevel(v)
+				continue
+			}
 			fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
 		} else {
 			fields[fmt.Sprint(keyvals[i])] = errMissingValue
 		}
 	}
 
-	switch l.level {
+	switch lvl {
 	case logrus.InfoLevel:
 		l.field.WithFields(fields).Info()
 	case logrus.ErrorLevel:
@@ -67,3 +83,16 @@ func (l Logger) Log(keyvals ...interface{}) error {
 
 	return nil
 }
+
+// logrusLevel returns the logrus level matching the value of the level key.
+func logrusLevel(v level.Value) logrus.Level {
+	switch v {
+	case level.DebugValue():
+		return logrus.DebugLevel
+	case level.WarnValue():
+		return logrus.WarnLevel
+	case level.ErrorValue():
+		return logrus.ErrorLevel
+	}
+	return logrus.InfoLevel
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	kitlog "github.com/mishudark/kit/log"
+	"github.com/mishudark/kit/log/level"
 	log "github.com/mishudark/kit/log/logrus"
 	"github.com/sirupsen/logrus"
 )
@@ -117,3 +119,58 @@ func TestWithLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestWithLevelKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		logger        func(kitlog.Logger) kitlog.Logger
+		expectedLevel logrus.Level
+	}{
+		{
+			name:          "Test Debug level",
+			logger:        level.Debug,
+			expectedLevel: logrus.DebugLevel,
+		},
+		{
+			name:          "Test Warn level",
+			logger:        level.Warn,
+			expectedLevel: logrus.WarnLevel,
+		},
+		{
+			name:          "Test Error level",
+			logger:        level.Error,
+			expectedLevel: logrus.ErrorLevel,
+		},
+		{
+			name:          "Test no level",
+			logger:        func(l kitlog.Logger) kitlog.Logger { return l },
+			expectedLevel: logrus.TraceLevel,
+		},
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		logrusLogger := logrus.New()
+		logrusLogger.Out = buf
+		logrusLogger.Level = logrus.TraceLevel
+		logrusLogger.Formatter = &logrus.JSONFormatter{}
+		logger := log.NewLogger(logrusLogger, log.WithLevel(logrus.TraceLevel), log.WithLevelKey())
+
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.logger(logger).Log("a", 1); err != nil {
+				t.Fatal(err)
+			}
+
+			l := map[string]interface{}{}
+			if err := json.Unmarshal(buf.Bytes(), &l); err != nil {
+				t.Fatal(err)
+			}
+
+			if v, ok := l["level"].(string); !ok || v != tt.expectedLevel.String() {
+				t.Fatalf("Logging levels doesn't match. Expected: %s, got: %s", tt.expectedLevel, v)
+			}
+			if want, have := float64(1), l["a"]; want != have {
+				t.Errorf("want %v, have %v", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package kit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit"
+	"github.com/mishudark/kit/log"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+
+	handler := kit.NewServer(
+		func(ctx context.Context, _ *http.Request) (interface{}, error) {
+			l, _ := log.FromContext(ctx)
+			l.Log("msg", "handling")
+			return nil, errors.New("boom")
+		},
+		kit.EncodeJSONResponse,
+		kit.ServerBefore(kit.PopulateRequestContext, kit.RequestLogger(logger)),
+		kit.ServerErrorHandler(kit.NewLogErrorHandler(log.NewNopLogger())),
+		kit.ServerFinalizer(kit.AccessLogFinalizer(log.NewNopLogger())),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	prefix := "request_id=abc method=POST path=/orders remote_addr=192.0.2.1:1234 "
+	want := []string{
+		prefix + "msg=handling",
+		prefix + "msg=access status=500 bytes=4 user_agent=",
+	}
+	if want, have := strings.Join(want, "\n"), strings.TrimSpace(buf.String()); want != have {
+		t.Errorf("\nwant:\n%s\nhave:\n%s", want, have)
+	}
+}
+
+func TestLogErrorHandlerRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { return nil, errors.New("boom") },
+		kit.EncodeJSONResponse,
+		kit.ServerBefore(kit.PopulateRequestContext, kit.RequestLogger(log.NewNopLogger())),
+		kit.ServerErrorLogger(log.NewLogfmtLogger(&buf)),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the configured logger is used, not the one bound to the context
+	if want, have := "request_id=abc method=POST path=/orders err=boom\n", buf.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestAccessLogFinalizerWithoutContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { return "ok", nil },
+		kit.EncodeJSONResponse,
+		kit.ServerFinalizer(kit.AccessLogFinalizer(log.NewLogfmtLogger(&buf))),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("User-Agent", "probe/1.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want, have := "msg=access method=GET path=/health status=200 bytes=5 user_agent=probe/1.0\n", buf.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestAccessLogFinalizerOptions(t *testing.T) {
+	tc := []struct {
+		name    string
+		options []kit.AccessLogOption
+		err     error
+		want    string
+	}{
+		{
+			name:    "fields",
+			options: []kit.AccessLogOption{kit.AccessLogFields(kit.AccessLogStatus, kit.AccessLogPath)},
+			want:    "msg=access path=/health status=200\n",
+		},
+		{
+			name:    "sampled success",
+			options: []kit.AccessLogOption{kit.AccessLogSampleSuccess(0)},
+			want:    "",
+		},
+		{
+			name:    "sampled server error",
+			options: []kit.AccessLogOption{kit.AccessLogSampleSuccess(0), kit.AccessLogFields(kit.AccessLogStatus)},
+			err:     errors.New("boom"),
+			want:    "msg=access status=500\n",
+		},
+		{
+			name:    "rate above one",
+			options: []kit.AccessLogOption{kit.AccessLogSampleSuccess(2), kit.AccessLogFields(kit.AccessLogStatus)},
+			want:    "msg=access status=200\n",
+		},
+		{
+			name:    "rate below zero",
+			options: []kit.AccessLogOption{kit.AccessLogSampleSuccess(-1)},
+			want:    "",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := kit.NewServer(
+				func(context.Context, *http.Request) (interface{}, error) { return "ok", tt.err },
+				kit.EncodeJSONResponse,
+				kit.ServerErrorHandler(kit.NewLogErrorHandler(log.NewNopLogger())),
+				kit.ServerFinalizer(kit.AccessLogFinalizer(log.NewLogfmtLogger(&buf), tt.options...)),
+			)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if want, have := tt.want, buf.String(); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestAccessLogFinalizerDuration(t *testing.T) {
+	var have time.Duration
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		for i := 0; i < len(keyvals)-1; i += 2 {
+			if keyvals[i] == kit.AccessLogDuration {
+				have = keyvals[i+1].(time.Duration)
+			}
+		}
+		return nil
+	})
+
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "ok", nil
+		},
+		kit.EncodeJSONResponse,
+		kit.ServerBefore(kit.RecordStartTime),
+		kit.ServerFinalizer(kit.AccessLogFinalizer(logger)),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if want := 10 * time.Millisecond; have < want {
+		t.Errorf("want a duration of at least %s, have %s", want, have)
+	}
+}
+
+func TestAccessLogFieldsWithContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { return "ok", nil },
+		kit.EncodeJSONResponse,
+		kit.ServerBefore(kit.RequestLogger(log.NewLogfmtLogger(&buf))),
+		kit.ServerFinalizer(kit.AccessLogFinalizer(log.NewNopLogger(), kit.AccessLogFields(kit.AccessLogStatus))),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	// the fields bound by RequestLogger are kept, the selection applies to the others
+	if want, have := "method=GET path=/health remote_addr=192.0.2.1:1234 msg=access status=200\n", buf.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,88 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mishudark/kit"
+)
+
+func TestServerRequestID(t *testing.T) {
+	tc := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "kept", incoming: "abc-123", want: "abc-123"},
+		{name: "missing", want: "generated"},
+		{name: "invalid", incoming: "abc 123", want: "generated"},
+		{name: "too long", incoming: strings.Repeat("a", 129), want: "generated"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var have string
+			handler := kit.NewServer(
+				func(ctx context.Context, _ *http.Request) (interface{}, error) {
+					have, _ = ctx.Value(kit.ContextKeyRequestXRequestID).(string)
+					return nil, errors.New("boom")
+				},
+				kit.EncodeJSONResponse,
+				kit.ServerRequestID(func() string { return "generated" }),
+				kit.ServerBefore(kit.PopulateRequestContext),
+				kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(context.Context, error) {})),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(kit.RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want := tt.want; want != have {
+				t.Errorf("context: want %q, have %q", want, have)
+			}
+			if want, have := tt.want, rec.Header().Get(kit.RequestIDHeader); want != have {
+				t.Errorf("response: want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	tc := []struct {
+		name string
+		gen  kit.IDGenerator
+		re   *regexp.Regexp
+	}{
+		{name: "uuid", gen: kit.NewUUID, re: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{name: "ulid", gen: kit.NewULID, re: regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.gen(), tt.gen()
+			if !tt.re.MatchString(a) {
+				t.Errorf("malformed id %q", a)
+			}
+			if a == b {
+				t.Errorf("duplicated id %q", a)
+			}
+		})
+	}
+}
+
+func TestRequestIDValuer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := kit.RequestID(func() string { return "abc" })(context.Background(), req)
+
+	if want, have := "abc", kit.RequestIDValuer(ctx)(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
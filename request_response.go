@@ -47,6 +47,10 @@ func SetRequestHeader(key, val string) RequestFunc {
 // the context from the HTTP request. Those values may be extracted using the
 // corresponding ContextKey type in this package.
 func PopulateRequestContext(ctx context.Context, r *http.Request) context.Context {
+	requestID := r.Header.Get("X-Request-Id")
+	if id, ok := ctx.Value(ContextKeyRequestXRequestID).(string); ok && id != "" {
+		requestID = id
+	}
 	for k, v := range map[contextKey]string{
 		ContextKeyRequestMethod:          r.Method,
 		ContextKeyRequestURI:             r.RequestURI,
@@ -59,11 +63,14 @@ func PopulateRequestContext(ctx context.Context, r *http.Request) context.Contex
 		ContextKeyRequestAuthorization:   r.Header.Get("Authorization"),
 		ContextKeyRequestReferer:         r.Header.Get("Referer"),
 		ContextKeyRequestUserAgent:       r.Header.Get("User-Agent"),
-		ContextKeyRequestXRequestID:      r.Header.Get("X-Request-Id"),
+		ContextKeyRequestXRequestID:      requestID,
 		ContextKeyRequestAccept:          r.Header.Get("Accept"),
 	} {
 		ctx = context.WithValue(ctx, k, v)
 	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		ctx = context.WithValue(ctx, ContextKeyRequestPeerCertificate, r.TLS.VerifiedChains[0][0])
+	}
 	return ctx
 }
 
@@ -115,7 +122,9 @@ const (
 	ContextKeyRequestUserAgent
 
 	// ContextKeyRequestXRequestID is populated in the context by
-	// PopulateRequestContext. Its value is r.Header.Get("X-Request-Id").
+	// PopulateRequestContext and RequestID. Its value is
+	// r.Header.Get("X-Request-Id"), unless the id was already set by
+	// RequestID.
 	ContextKeyRequestXRequestID
 
 	// ContextKeyRequestAccept is populated in the context by
@@ -130,4 +139,15 @@ const (
 	// ContextKeyResponseSize is populated in the context whenever a
 	// ServerFinalizerFunc is specified. Its value is of type int64.
 	ContextKeyResponseSize
+
+	// ContextKeyRequestPeerCertificate is populated in the context by
+	// PopulateRequestContext when the client presented a certificate which
+	// was verified, see httputil.WithClientCAs. Its value is of type
+	// *x509.Certificate, the leaf of the first verified chain.
+	ContextKeyRequestPeerCertificate
+
+	// ContextKeyRequestStartTime is populated in the context by
+	// RecordStartTime. Its value is of type time.Time, the time the request
+	// started to be processed.
+	ContextKeyRequestStartTime
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/mishudark/kit/log"
 )
@@ -26,6 +27,11 @@ type Server struct {
 	errorEncoder ErrorEncoder
 	finalizer    []ServerFinalizerFunc
 	errorHandler ErrorHandler
+	recovery     RecoveryHandler
+
+	timeout       time.Duration
+	timeoutHeader string
+	requestID     RequestFunc
 }
 
 // NewServer constructs a new server, which implements http.Handler and wraps
@@ -95,12 +101,28 @@ func ServerFinalizer(f ...ServerFinalizerFunc) ServerOption {
 	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
 }
 
+// ServerRecover recovers from panics raised while processing a request, the
+// RecoveryHandler turns the panic into an error which is passed to the
+// ErrorHandler and written with the ErrorEncoder. Finalizers observe the
+// status code written by the ErrorEncoder. When the response was already
+// committed, the error is only passed to the ErrorHandler and finalizers
+// observe the committed status code. By default, panics are not recovered.
+func ServerRecover(rh RecoveryHandler) ServerOption {
+	return func(s *Server) { s.recovery = rh }
+}
+
 // ServeHTTP implements http.Handler.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	// the writer tracks whether the response was committed for the recovery
+	var iw *interceptingWriter
+	if len(s.finalizer) > 0 || s.recovery != nil {
+		iw = &interceptingWriter{ResponseWriter: w, code: http.StatusOK}
+		w = iw
+	}
+
 	if len(s.finalizer) > 0 {
-		iw := &interceptingWriter{w, http.StatusOK, 0}
 		defer func() {
 			ctx = context.WithValue(ctx, ContextKeyResponseHeaders, iw.Header())
 			ctx = context.WithValue(ctx, ContextKeyResponseSize, iw.written)
@@ -108,19 +130,54 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				f(ctx, iw.code, r)
 			}
 		}()
-		w = iw
+	}
+
+	if s.recovery != nil {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			err := s.recovery(ctx, p)
+			s.errorHandler.Handle(ctx, err)
+			if !iw.wroteHeader {
+				s.errorEncoder(ctx, err, w)
+			}
+		}()
+	}
+
+	if s.requestID != nil {
+		ctx = s.requestID(ctx, r)
+		id, _ := ctx.Value(ContextKeyRequestXRequestID).(string)
+		w.Header().Set(RequestIDHeader, id)
 	}
 
 	for _, f := range s.before {
 		ctx = f(ctx, r)
 	}
 
-	response, err := s.handler(ctx, r)
+	// the deadline only bounds the handler
+	base := ctx
+	var timeout time.Duration
+	if s.timeout > 0 {
+		timeout = requestTimeout(r, s.timeout, s.timeoutHeader)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	response, err := awaitDeadline(ctx, base, timeout, func() (interface{}, error) {
+		return s.handler(ctx, r)
+	})
 	if err != nil {
 		s.errorHandler.Handle(ctx, err)
 		s.errorEncoder(ctx, err, w)
 		return
 	}
+	ctx = base
 
 	for _, f := range s.after {
 		ctx = f(ctx, w)
@@ -185,6 +242,16 @@ func EncodeJSONResponse(_ context.Context, w http.ResponseWriter, response inter
 // encoded form of the error will be used. If the error implements StatusCoder,
 // the provided StatusCode will be used instead of 500.
 func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	code := http.StatusInternalServerError
+	if sc, ok := err.(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	writeError(w, err, code)
+}
+
+// writeError writes the error with the given status code, as described by
+// DefaultErrorEncoder.
+func writeError(w http.ResponseWriter, err error, code int) {
 	contentType, body := "text/plain; charset=utf-8", []byte(err.Error())
 	if marshaler, ok := err.(json.Marshaler); ok {
 		if jsonBody, marshalErr := marshaler.MarshalJSON(); marshalErr == nil {
@@ -199,10 +266,6 @@ func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 			}
 		}
 	}
-	code := http.StatusInternalServerError
-	if sc, ok := err.(StatusCoder); ok {
-		code = sc.StatusCode()
-	}
 	w.WriteHeader(code)
 	w.Write(body)
 }
@@ -223,18 +286,23 @@ type Headerer interface {
 
 type interceptingWriter struct {
 	http.ResponseWriter
-	code    int
-	written int64
+	code        int
+	written     int64
+	wroteHeader bool
 }
 
 // WriteHeader may not be explicitly called, so care must be taken to
 // initialize w.code to its default value of http.StatusOK.
 func (w *interceptingWriter) WriteHeader(code int) {
-	w.code = code
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = code >= 200 // informational responses are not final
+	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
 func (w *interceptingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
 	n, err := w.ResponseWriter.Write(p)
 	w.written += int64(n)
 	return n, err
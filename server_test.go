@@ -0,0 +1,221 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit"
+)
+
+func TestServerRecover(t *testing.T) {
+	var (
+		handled   error
+		finalCode int
+	)
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { panic("boom") },
+		kit.EncodeJSONResponse,
+		kit.ServerRecover(kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		kit.ServerFinalizer(func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want, have := http.StatusInternalServerError, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := http.StatusInternalServerError, finalCode; want != have {
+		t.Errorf("finalizer: want %d, have %d", want, have)
+	}
+
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Fatalf("want *kit.PanicError, have %T", handled)
+	}
+	if want, have := "boom", panicErr.Value; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("want a stack trace, have none")
+	}
+}
+
+func TestServerRecoverCommitted(t *testing.T) {
+	var (
+		handled   error
+		finalCode int
+	)
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { return "ok", nil },
+		func(_ context.Context, w http.ResponseWriter, _ interface{}) error {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			panic("boom")
+		},
+		kit.ServerRecover(kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		kit.ServerFinalizer(func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := "partial", rec.Body.String(); want != have {
+		t.Errorf("want body %q, have %q", want, have)
+	}
+	if want, have := http.StatusOK, finalCode; want != have {
+		t.Errorf("finalizer: want %d, have %d", want, have)
+	}
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Errorf("want *kit.PanicError, have %T", handled)
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	slow := func(ctx context.Context, _ *http.Request) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return "done", nil
+		}
+	}
+
+	tc := [...]struct {
+		name    string
+		timeout time.Duration
+		header  string
+		want    int
+	}{
+		{name: "no deadline", timeout: 0, want: http.StatusOK},
+		{name: "deadline exceeded", timeout: 10 * time.Millisecond, want: http.StatusGatewayTimeout},
+		{name: "shortened by header", timeout: time.Minute, header: "10ms", want: http.StatusGatewayTimeout},
+		{name: "header in seconds", timeout: time.Minute, header: "0.01", want: http.StatusGatewayTimeout},
+		{name: "header can not extend", timeout: 10 * time.Millisecond, header: "1m", want: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled error
+			options := []kit.ServerOption{
+				kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+			}
+			if tt.timeout > 0 {
+				options = append(options, kit.ServerTimeout(tt.timeout))
+			}
+			handler := kit.NewServer(slow, kit.EncodeJSONResponse, options...)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(kit.DefaultTimeoutHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, have := tt.want, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+
+			var timeoutErr *kit.TimeoutError
+			if want, have := tt.want == http.StatusGatewayTimeout, errors.As(handled, &timeoutErr); want != have {
+				t.Errorf("want timeout error %v, have %v", want, handled)
+			}
+		})
+	}
+}
+
+func TestServerTimeoutLateResult(t *testing.T) {
+	// the handler ignoring its context is released once the response is written
+	release := make(chan struct{})
+	defer close(release)
+
+	tc := [...]struct {
+		name    string
+		timeout time.Duration
+		parent  time.Duration
+		handler kit.Handler
+		want    int
+	}{
+		{
+			name:    "success past the deadline",
+			timeout: 10 * time.Millisecond,
+			handler: func(context.Context, *http.Request) (interface{}, error) {
+				<-release
+				return "done", nil
+			},
+			want: http.StatusGatewayTimeout,
+		},
+		{
+			name:    "inherited deadline",
+			timeout: time.Minute,
+			parent:  10 * time.Millisecond,
+			handler: func(ctx context.Context, _ *http.Request) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled error
+			handler := kit.NewServer(
+				tt.handler,
+				kit.EncodeJSONResponse,
+				kit.ServerTimeout(tt.timeout),
+				kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.parent > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), tt.parent)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, have := tt.want, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			var timeoutErr *kit.TimeoutError
+			if want, have := tt.want == http.StatusGatewayTimeout, errors.As(handled, &timeoutErr); want != have {
+				t.Errorf("want timeout error %v, have %v", want, handled)
+			}
+		})
+	}
+}
+
+func TestServerTimeoutRecover(t *testing.T) {
+	var handled error
+	handler := kit.NewServer(
+		func(context.Context, *http.Request) (interface{}, error) { panic("boom") },
+		kit.EncodeJSONResponse,
+		kit.ServerTimeout(time.Minute),
+		kit.ServerRecover(kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// the panic of the handler goroutine reaches the recovery of the server
+	if want, have := http.StatusInternalServerError, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Errorf("want *kit.PanicError, have %T", handled)
+	}
+}
@@ -0,0 +1,221 @@
+package kit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// HTTPClient is an interface that models *http.Client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// EncodeRequestFunc encodes the passed request object into the HTTP request
+// object. It's designed to be used in HTTP clients, for client-side
+// endpoints. One straightforward EncodeRequestFunc could be something that JSON
+// encodes the object directly to the request body.
+type EncodeRequestFunc[I any] func(context.Context, *http.Request, I) error
+
+// DecodeResponseFunc extracts a user-domain response object from an HTTP
+// response object. It's designed to be used in HTTP clients, for client-side
+// endpoints. One straightforward DecodeResponseFunc could be something that
+// JSON decodes from the response body to the concrete response type.
+type DecodeResponseFunc[O any] func(context.Context, *http.Response) (resp O, err error)
+
+// ClientFinalizerFunc can be used to perform work at the end of a client HTTP
+// request, after the response is returned. The principal
+// intended use is for error logging. Additional response parameters are
+// provided in the context under keys with the ContextKeyResponse prefix.
+// Note: err may be nil. There maybe also no additional response parameters
+// depending on when an error occurs.
+type ClientFinalizerFunc func(ctx context.Context, err error)
+
+// Client wraps a URL and provides a method that implements HandlerFunc, it's
+// the client-side counterpart of Server.
+type Client[I, O any] struct {
+	client    HTTPClient
+	method    string
+	tgt       *url.URL
+	enc       EncodeRequestFunc[I]
+	dec       DecodeResponseFunc[O]
+	before    []RequestFunc
+	after     []ClientResponseFunc
+	finalizer []ClientFinalizerFunc
+}
+
+// NewClient constructs a usable Client for a single remote method.
+func NewClient[I, O any](
+	method string,
+	tgt *url.URL,
+	enc EncodeRequestFunc[I],
+	dec DecodeResponseFunc[O],
+	options ...ClientOption[I, O],
+) *Client[I, O] {
+	c := &Client[I, O]{
+		client: http.DefaultClient,
+		method: method,
+		tgt:    tgt,
+		enc:    enc,
+		dec:    dec,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// ClientOption sets an optional parameter for clients.
+type ClientOption[I, O any] func(*Client[I, O])
+
+// SetClient sets the underlying HTTP client used for requests.
+// By default, http.DefaultClient is used.
+func SetClient[I, O any](client HTTPClient) ClientOption[I, O] {
+	return func(c *Client[I, O]) { c.client = client }
+}
+
+// ClientBefore adds one or more RequestFuncs to be applied to the outgoing HTTP
+// request before it's invoked.
+func ClientBefore[I, O any](before ...RequestFunc) ClientOption[I, O] {
+	return func(c *Client[I, O]) { c.before = append(c.before, before...) }
+}
+
+// ClientAfter adds one or more ClientResponseFuncs, which are applied to the
+// incoming HTTP response prior to it being decoded. This is useful for
+// obtaining anything off of the response and adding it into the context prior
+// to decoding.
+func ClientAfter[I, O any](after ...ClientResponseFunc) ClientOption[I, O] {
+	return func(c *Client[I, O]) { c.after = append(c.after, after...) }
+}
+
+// ClientFinalizer adds one or more ClientFinalizerFuncs to be executed at the
+// end of every HTTP request. Finalizers are executed in the order in which they
+// were added. By default, no finalizer is registered.
+func ClientFinalizer[I, O any](f ...ClientFinalizerFunc) ClientOption[I, O] {
+	return func(c *Client[I, O]) { c.finalizer = append(c.finalizer, f...) }
+}
+
+// Endpoint returns a usable HandlerFunc that invokes the remote endpoint. The
+// id of the request carried by the context, see RequestID, is forwarded in
+// the RequestIDHeader, unless the encoder already set it.
+func (c Client[I, O]) Endpoint() HandlerFunc[I, O] {
+	return func(ctx context.Context, request I) (response O, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var resp *http.Response
+		if len(c.finalizer) > 0 {
+			defer func() {
+				if resp != nil {
+					ctx = context.WithValue(ctx, ContextKeyResponseHeaders, resp.Header)
+					ctx = context.WithValue(ctx, ContextKeyResponseSize, resp.ContentLength)
+				}
+				for _, f := range c.finalizer {
+					f(ctx, err)
+				}
+			}()
+		}
+
+		req, err := http.NewRequest(c.method, c.tgt.String(), nil)
+		if err != nil {
+			return response, err
+		}
+
+		if err = c.enc(ctx, req, request); err != nil {
+			return response, err
+		}
+
+		if id, ok := ctx.Value(ContextKeyRequestXRequestID).(string); ok && id != "" && req.Header.Get(RequestIDHeader) == "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+
+		for _, f := range c.before {
+			ctx = f(ctx, req)
+		}
+
+		resp, err = c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return response, err
+		}
+		defer resp.Body.Close()
+
+		for _, f := range c.after {
+			ctx = f(ctx, resp)
+		}
+
+		return c.dec(ctx, resp)
+	}
+}
+
+// EncodeJSONRequest is an EncodeRequestFunc that serializes the request as a
+// JSON object to the Request body. Many JSON-over-HTTP services can use it as
+// a sensible default. If the request implements Headerer, the provided headers
+// will be applied to the request.
+func EncodeJSONRequest[I any](_ context.Context, r *http.Request, request I) error {
+	// convert request to an interface to check if it implements Headerer
+	var req any = request
+
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if headerer, ok := req.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(request); err != nil {
+		return err
+	}
+
+	r.ContentLength = int64(b.Len())
+	r.Body = io.NopCloser(&b)
+	return nil
+}
+
+// DecodeJSONResponse is a DecodeResponseFunc that deserializes a JSON object
+// from the response body. Responses with a status code outside of the 2xx
+// range are returned as a *ResponseError.
+func DecodeJSONResponse[O any](_ context.Context, r *http.Response) (resp O, err error) {
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		body, _ := io.ReadAll(r.Body)
+		return resp, &ResponseError{Code: r.StatusCode, Body: body}
+	}
+
+	if r.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&resp)
+	if err == io.EOF {
+		err = nil
+	}
+
+	return resp, err
+}
+
+var _ StatusCoder = (*ResponseError)(nil)
+
+// ResponseError is returned by DecodeJSONResponse when the remote endpoint
+// answers with a non 2xx status code.
+type ResponseError struct {
+	Code int
+	Body []byte
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.Code, bytes.TrimSpace(e.Body))
+}
+
+// StatusCode returns the status code of the remote response.
+func (e *ResponseError) StatusCode() int {
+	return e.Code
+}
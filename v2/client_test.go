@@ -0,0 +1,95 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestClientEndpoint(t *testing.T) {
+	srv := httptest.NewServer(kit.NewServer(
+		greet,
+		kit.DecodeRequest[testRequest],
+		kit.EncodeJSONResponse[testResponse],
+	))
+	defer srv.Close()
+
+	tgt, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const headerKey, headerVal = "X-Foo", "bar"
+	var (
+		beforeCalled bool
+		afterHeader  string
+		finalErr     = errors.New("not called")
+	)
+	client := kit.NewClient(
+		http.MethodPost,
+		tgt,
+		kit.EncodeJSONRequest[testRequest],
+		kit.DecodeJSONResponse[testResponse],
+		kit.ClientBefore[testRequest, testResponse](func(ctx context.Context, r *http.Request) context.Context {
+			beforeCalled = r.Header.Get("Content-Type") != ""
+			return ctx
+		}),
+		kit.ClientAfter[testRequest, testResponse](func(ctx context.Context, r *http.Response) context.Context {
+			afterHeader = r.Header.Get("Content-Type")
+			return ctx
+		}),
+		kit.ClientFinalizer[testRequest, testResponse](func(_ context.Context, err error) {
+			finalErr = err
+		}),
+	)
+
+	resp, err := client.Endpoint()(context.Background(), testRequest{Name: "kit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hello kit", resp.Greeting; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if !beforeCalled {
+		t.Error("before func not called after encoding the request")
+	}
+	if want, have := "application/json; charset=utf-8", afterHeader; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if finalErr != nil {
+		t.Errorf("want nil finalizer error, have %v", finalErr)
+	}
+}
+
+func TestClientResponseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "nope", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	tgt, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := kit.NewClient(
+		http.MethodGet,
+		tgt,
+		kit.EncodeJSONRequest[testRequest],
+		kit.DecodeJSONResponse[testResponse],
+	)
+
+	_, err = client.Endpoint()(context.Background(), testRequest{})
+	var respErr *kit.ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("want *kit.ResponseError, have %v", err)
+	}
+	if want, have := http.StatusNotFound, respErr.StatusCode(); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
@@ -2,33 +2,335 @@ package kit
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/mishudark/errors"
 	"github.com/mishudark/magic"
 )
 
-// DecodeSaveRequest decodes a POST, PUT or PATCH request
+const (
+	headerTag = "header"
+	cookieTag = "cookie"
+	formTag   = "form"
+)
+
+// defaultDecoder is the decoder used by DecodeRequest.
+var defaultDecoder = newDecoder()
+
+// DecodeRequest decodes the request body for POST, PUT or PATCH requests and
+// the query params for GET or DELETE requests, along with the chi router
+// params. Then the decoded request is checked with Validate.
 func DecodeRequest[I any](ctx context.Context, r *http.Request) (in I, err error) {
-	switch r.Method {
-	case "POST", "PUT", "PATCH":
-		err = magic.Decode(r, &in,
-			magic.JSON,
-			magic.ChiRouter,
-		)
-	case "GET", "DELETE":
-		err = magic.Decode(r, &in,
-			magic.QueryParams,
-			magic.ChiRouter,
-		)
-	default:
+	return decodeRequest[I](defaultDecoder, r)
+}
+
+// DecoderOption sets an optional parameter for decoders.
+type DecoderOption func(*decoder)
+
+// decoder holds the sources used to decode the requests of every method.
+type decoder struct {
+	sources        map[string][]magic.Decoder
+	allowEmptyBody bool
+	maxBodySize    int64
+	json           jsonOptions
+}
+
+// jsonOptions configures JSONBody, they are passed through the request
+// context.
+type jsonOptions struct {
+	disallowUnknownFields bool
+	rejectTrailingData    bool
+	useNumber             bool
+}
+
+type jsonOptionsKey struct{}
+
+func newDecoder(options ...DecoderOption) *decoder {
+	d := &decoder{
+		sources: map[string][]magic.Decoder{
+			http.MethodPost:   {JSONBody, magic.ChiRouter},
+			http.MethodPut:    {JSONBody, magic.ChiRouter},
+			http.MethodPatch:  {JSONBody, magic.ChiRouter},
+			http.MethodGet:    {magic.QueryParams, magic.ChiRouter},
+			http.MethodDelete: {magic.QueryParams, magic.ChiRouter},
+		},
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d
+}
+
+// NewDecoder returns a DecodeRequestFunc that works as DecodeRequest, the
+// sources used for each method and the handling of empty bodies can be
+// changed with the given options. For instance, to decode url encoded bodies
+// along with the gorilla mux params on POST, and to accept HEAD requests:
+//
+//	dec := NewDecoder[Foo](
+//		DecoderSources(http.MethodPost, FormBody, magic.MuxRouter),
+//		DecoderSources(http.MethodHead, magic.QueryParams),
+//	)
+func NewDecoder[I any](options ...DecoderOption) DecodeRequestFunc[I] {
+	d := newDecoder(options...)
+	return func(ctx context.Context, r *http.Request) (I, error) {
+		return decodeRequest[I](d, r)
+	}
+}
+
+// DecoderSources sets the sources used to decode the requests with the given
+// method, replacing the default ones. Sources are applied in the given order.
+// Requests with a method without sources are rejected, so this option also
+// allows to support new methods.
+func DecoderSources(method string, sources ...magic.Decoder) DecoderOption {
+	return func(d *decoder) { d.sources[strings.ToUpper(method)] = sources }
+}
+
+// DecoderAllowEmptyBody sets whether an empty body is accepted, in that case
+// the sources reading the body are skipped. By default, an empty body is an
+// error.
+func DecoderAllowEmptyBody(allow bool) DecoderOption {
+	return func(d *decoder) { d.allowEmptyBody = allow }
+}
+
+// DecoderMaxBodySize limits the size of the request body, bigger bodies are
+// rejected with a *BodyTooLargeError. By default, the size is not limited.
+func DecoderMaxBodySize(n int64) DecoderOption {
+	return func(d *decoder) { d.maxBodySize = n }
+}
+
+// DecoderDisallowUnknownFields makes JSONBody reject the objects with keys
+// which do not match any exported field of the request, with an
+// *UnknownFieldError.
+func DecoderDisallowUnknownFields() DecoderOption {
+	return func(d *decoder) { d.json.disallowUnknownFields = true }
+}
+
+// DecoderRejectTrailingData makes JSONBody reject the bodies with data after
+// the JSON value, with a *TrailingDataError.
+func DecoderRejectTrailingData() DecoderOption {
+	return func(d *decoder) { d.json.rejectTrailingData = true }
+}
+
+// DecoderUseNumber makes JSONBody decode the numbers held by interface
+// values as a json.Number instead of as a float64.
+func DecoderUseNumber() DecoderOption {
+	return func(d *decoder) { d.json.useNumber = true }
+}
+
+func decodeRequest[I any](d *decoder, r *http.Request) (in I, err error) {
+	sources, ok := d.sources[r.Method]
+	if !ok {
 		err = errors.Errorf("method %s not supported", r.Method)
+		return in, errors.E(err, "can not unmarshal request", errors.Unmarshal)
+	}
+
+	if d.maxBodySize > 0 && r.Body != nil {
+		if r.ContentLength > d.maxBodySize {
+			return in, &BodyTooLargeError{Limit: d.maxBodySize}
+		}
+		r.Body = &maxBodyReader{rc: r.Body, n: d.maxBodySize, limit: d.maxBodySize}
+	}
+
+	if d.json != (jsonOptions{}) {
+		r = r.WithContext(context.WithValue(r.Context(), jsonOptionsKey{}, d.json))
+	}
+
+	for _, source := range sources {
+		err = magic.Decode(r, &in, source)
+		if err == io.EOF {
+			if d.allowEmptyBody {
+				continue
+			}
+			err = errors.New("empty body")
+		}
+		if _, ok := err.(StatusCoder); ok {
+			// typed errors carry their own status code
+			return in, err
+		}
+		if err != nil {
+			return in, errors.E(err, "can not unmarshal request", errors.Unmarshal)
+		}
+	}
+
+	return in, Validate(&in)
+}
+
+// FormBody extracts the fields tagged with form from an url encoded body.
+func FormBody(r *http.Request, container any) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(r.PostForm))
+	for k := range r.PostForm {
+		values[k] = r.PostForm.Get(k)
+	}
+
+	return magic.ParseToStruct(formTag, values, container)
+}
+
+// HeaderParams extracts the fields tagged with header from the request
+// headers, the tag must be either the canonical or the lower case form of
+// the header name.
+func HeaderParams(r *http.Request, container any) error {
+	values := make(map[string]string, 2*len(r.Header))
+	for k := range r.Header {
+		v := r.Header.Get(k)
+		values[k] = v
+		values[strings.ToLower(k)] = v
+	}
+
+	return magic.ParseToStruct(headerTag, values, container)
+}
+
+// CookieParams extracts the fields tagged with cookie from the request
+// cookies.
+func CookieParams(r *http.Request, container any) error {
+	values := make(map[string]string)
+	for _, c := range r.Cookies() {
+		values[c.Name] = c.Value
+	}
+
+	return magic.ParseToStruct(cookieTag, values, container)
+}
+
+// JSONBody decodes a JSON body, honoring the DecoderDisallowUnknownFields,
+// DecoderRejectTrailingData and DecoderUseNumber options of the decoder.
+func JSONBody(r *http.Request, container any) error {
+	if r.Body == nil {
+		return errors.New("empty request body")
+	}
+
+	opts, _ := r.Context().Value(jsonOptionsKey{}).(jsonOptions)
+
+	dec := json.NewDecoder(r.Body)
+	if opts.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if opts.useNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(container); err != nil {
+		// json reports unknown fields with a plain error
+		const unknownField = "json: unknown field "
+		if msg := err.Error(); strings.HasPrefix(msg, unknownField) {
+			field, unquoteErr := strconv.Unquote(strings.TrimPrefix(msg, unknownField))
+			if unquoteErr == nil {
+				return &UnknownFieldError{Field: field}
+			}
+		}
+		return err
+	}
+
+	if opts.rejectTrailingData {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*BodyTooLargeError); ok {
+			return err
+		}
+		return &TrailingDataError{}
+	}
+
+	return nil
+}
+
+// maxBodyReader works as http.MaxBytesReader, but it returns a
+// *BodyTooLargeError when the limit is exceeded.
+type maxBodyReader struct {
+	rc    io.ReadCloser
+	n     int64 // remaining bytes
+	limit int64
+	err   error
+}
+
+func (l *maxBodyReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(p) == 0 {
+		return 0, nil
 	}
 
-	if err == io.EOF {
-		err = errors.New("empty body")
+	// read one extra byte to detect when the limit is exceeded
+	if int64(len(p))-1 > l.n {
+		p = p[:l.n+1]
 	}
 
-	return in, errors.E(err, "can not unmarshal request", errors.Unmarshal)
+	n, err := l.rc.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		l.err = err
+		return n, err
+	}
+
+	n = int(l.n)
+	l.n = 0
+	l.err = &BodyTooLargeError{Limit: l.limit}
+	return n, l.err
+}
+
+func (l *maxBodyReader) Close() error {
+	return l.rc.Close()
+}
+
+var (
+	_ StatusCoder = (*BodyTooLargeError)(nil)
+	_ StatusCoder = (*UnknownFieldError)(nil)
+	_ StatusCoder = (*TrailingDataError)(nil)
+)
+
+// BodyTooLargeError is returned when the request body exceeds the size set
+// with DecoderMaxBodySize.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+// Error implements the error interface.
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body too large, the limit is %d bytes", e.Limit)
+}
+
+// StatusCode returns 413 Request Entity Too Large.
+func (e *BodyTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// UnknownFieldError is returned when the JSON body has a key which does not
+// match any field of the request, see DecoderDisallowUnknownFields.
+type UnknownFieldError struct {
+	Field string
+}
+
+// Error implements the error interface.
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// StatusCode returns 400 Bad Request.
+func (e *UnknownFieldError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// TrailingDataError is returned when the JSON body has data after the JSON
+// value, see DecoderRejectTrailingData.
+type TrailingDataError struct{}
+
+// Error implements the error interface.
+func (e *TrailingDataError) Error() string {
+	return "unexpected data after the JSON value"
+}
+
+// StatusCode returns 400 Bad Request.
+func (e *TrailingDataError) StatusCode() int {
+	return http.StatusBadRequest
 }
@@ -0,0 +1,153 @@
+package kit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mishudark/errors"
+	kit "github.com/mishudark/kit/v2"
+	"github.com/mishudark/magic"
+)
+
+type sourcesRequest struct {
+	Name    string `form:"name"`
+	Agent   string `header:"user-agent"`
+	Session string `cookie:"session"`
+	Page    int    `form:"page"`
+}
+
+func TestNewDecoder(t *testing.T) {
+	dec := kit.NewDecoder[sourcesRequest](
+		kit.DecoderSources(http.MethodPost, kit.FormBody, kit.HeaderParams, kit.CookieParams),
+		kit.DecoderSources(http.MethodHead, magic.QueryParams),
+	)
+
+	body := url.Values{"name": {"kit"}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("User-Agent", "tester")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+
+	req, err := dec(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sourcesRequest{Name: "kit", Agent: "tester", Session: "s3cr3t"}
+	if want != req {
+		t.Errorf("want %+v, have %+v", want, req)
+	}
+
+	req, err = dec(context.Background(), httptest.NewRequest(http.MethodHead, "/?page=2", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, req.Page; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	_, err = dec(context.Background(), httptest.NewRequest(http.MethodOptions, "/", nil))
+	if !errors.IsKind(err, errors.Unmarshal) {
+		t.Errorf("want an Unmarshal error, have %v", err)
+	}
+}
+
+func TestDecoderAllowEmptyBody(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		dec := kit.NewDecoder[testRequest](kit.DecoderAllowEmptyBody(allow))
+		_, err := dec(context.Background(), httptest.NewRequest(http.MethodPost, "/", nil))
+		if allow && err != nil {
+			t.Errorf("allowed empty body: want nil, have %v", err)
+		}
+		if !allow && !errors.IsKind(err, errors.Unmarshal) {
+			t.Errorf("rejected empty body: want an Unmarshal error, have %v", err)
+		}
+	}
+}
+
+func TestDecoderStrictJSON(t *testing.T) {
+	type numberRequest struct {
+		Value any `json:"value"`
+	}
+
+	tc := [...]struct {
+		name  string
+		body  string
+		opt   kit.DecoderOption
+		err   error
+		code  int
+		value any
+	}{
+		{
+			name: "body too large",
+			body: `{"value": "` + strings.Repeat("a", 64) + `"}`,
+			opt:  kit.DecoderMaxBodySize(32),
+			err:  &kit.BodyTooLargeError{},
+			code: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "unknown field",
+			body: `{"value": 1, "other": 2}`,
+			opt:  kit.DecoderDisallowUnknownFields(),
+			err:  &kit.UnknownFieldError{},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "trailing data",
+			body: `{"value": 1} {"value": 2}`,
+			opt:  kit.DecoderRejectTrailingData(),
+			err:  &kit.TrailingDataError{},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "trailing whitespace",
+			body: "{\"value\": 1}\n",
+			opt:  kit.DecoderRejectTrailingData(),
+		},
+		{
+			name:  "use number",
+			body:  `{"value": 12345678901234567890}`,
+			opt:   kit.DecoderUseNumber(),
+			value: json.Number("12345678901234567890"),
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := kit.NewDecoder[numberRequest](tt.opt)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.ContentLength = -1 // unknown, so the limit is enforced while reading
+
+			req, err := dec(context.Background(), r)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("want nil, have %v", err)
+				}
+				if tt.value != nil && tt.value != req.Value {
+					t.Errorf("want %v, have %v", tt.value, req.Value)
+				}
+				return
+			}
+
+			if want, have := reflect.TypeOf(tt.err), reflect.TypeOf(err); want != have {
+				t.Fatalf("want %v, have %v: %v", want, have, err)
+			}
+			if want, have := tt.code, err.(kit.StatusCoder).StatusCode(); want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestDecoderMaxBodySizeContentLength(t *testing.T) {
+	dec := kit.NewDecoder[testRequest](kit.DecoderMaxBodySize(4))
+	_, err := dec(context.Background(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "kit"}`)))
+	if _, ok := err.(*kit.BodyTooLargeError); !ok {
+		t.Errorf("want *kit.BodyTooLargeError, have %v", err)
+	}
+}
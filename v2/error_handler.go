@@ -2,7 +2,9 @@ package kit
 
 import (
 	"context"
-	"log"
+	stdlog "log"
+
+	"github.com/mishudark/kit/log"
 )
 
 // ErrorHandler receives a transport error to be processed for diagnostic purposes.
@@ -11,9 +13,15 @@ type ErrorHandler interface {
 	Handle(ctx context.Context, err error)
 }
 
-// LogErrorHandler is a transport error handler
+// LogErrorHandler is a transport error handler, it logs the error through
+// the logger carried by the context, see RequestLogger, or through the
+// standard logger otherwise.
 func LogErrorHandler(ctx context.Context, err error) {
-	log.Println("ErrorHandlerFunc.Handle:", err)
+	if logger, ok := log.FromContext(ctx); ok {
+		logger.Log("err", err)
+		return
+	}
+	stdlog.Println("ErrorHandlerFunc.Handle:", err)
 }
 
 // The ErrorHandlerFunc type is an adapter to allow the use of
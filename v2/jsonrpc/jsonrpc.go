@@ -0,0 +1,120 @@
+// Package jsonrpc implements a JSON-RPC 2.0 transport over HTTP, the methods
+// are backed by the kit HandlerFunc, so they share the business logic with
+// the other transports.
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+// Version is the version of the protocol, it's required in every request.
+const Version = "2.0"
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// Request is a JSON-RPC request object. A request without id is a
+// notification, which is not answered.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC response object, either Result or Error is set.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error is a JSON-RPC error object. Methods may return it to control the
+// code, message and data sent to the client.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCoder is checked when a method returns an error. If the error
+// implements ErrorCoder, the ErrorCode will be used in the error object. By
+// default, InternalError is used.
+type ErrorCoder interface {
+	ErrorCode() int
+}
+
+// DecodeParamsFunc extracts a user-domain request object from the params of
+// a JSON-RPC request.
+type DecodeParamsFunc[I any] func(context.Context, json.RawMessage) (req I, err error)
+
+// DecodeParams is a DecodeParamsFunc that decodes the params, given by name,
+// into the request. Then the decoded request is checked with kit.Validate.
+func DecodeParams[I any](_ context.Context, params json.RawMessage) (in I, err error) {
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &in); err != nil {
+			return in, err
+		}
+	}
+	return in, kit.Validate(&in)
+}
+
+// Method is a JSON-RPC method, which takes the raw params and returns the
+// result to be serialized.
+type Method func(ctx context.Context, params json.RawMessage) (result any, err error)
+
+// NewMethod returns a Method that decodes the params with dec and invokes h.
+// The errors returned by dec are answered with InvalidParams.
+func NewMethod[I, O any](h kit.HandlerFunc[I, O], dec DecodeParamsFunc[I]) Method {
+	return func(ctx context.Context, params json.RawMessage) (any, error) {
+		in, err := dec(ctx, params)
+		if err != nil {
+			return nil, invalidParams(err)
+		}
+		return h(ctx, in)
+	}
+}
+
+func invalidParams(err error) error {
+	var rpcErr *Error
+	if stderrors.As(err, &rpcErr) {
+		return err
+	}
+
+	var validationErrs kit.ValidationErrors
+	if stderrors.As(err, &validationErrs) {
+		return &Error{Code: InvalidParams, Message: "Invalid params", Data: []kit.FieldError(validationErrs)}
+	}
+
+	return &Error{Code: InvalidParams, Message: "Invalid params", Data: err.Error()}
+}
+
+// toError converts the error returned by a method into an error object.
+func toError(err error) *Error {
+	var rpcErr *Error
+	if stderrors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	code := InternalError
+	if coder, ok := err.(ErrorCoder); ok {
+		code = coder.ErrorCode()
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
@@ -0,0 +1,246 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+// Server is a JSON-RPC 2.0 endpoint, which implements http.Handler. It
+// dispatches the requests to the registered methods, along with batches and
+// notifications.
+type Server struct {
+	methods      map[string]Method
+	before       []kit.RequestFunc
+	after        []kit.ServerResponseFunc
+	finalizer    []kit.ServerFinalizerFunc
+	errorHandler kit.ErrorHandler
+}
+
+// ServerOption sets an optional parameter for servers.
+type ServerOption func(*Server)
+
+// ServerBefore functions are executed on the HTTP request object before the
+// request is decoded.
+func ServerBefore(before ...kit.RequestFunc) ServerOption {
+	return func(s *Server) { s.before = append(s.before, before...) }
+}
+
+// ServerAfter functions are executed on the HTTP response writer after the
+// methods are invoked, but before anything is written to the client.
+func ServerAfter(after ...kit.ServerResponseFunc) ServerOption {
+	return func(s *Server) { s.after = append(s.after, after...) }
+}
+
+// ServerFinalizer is executed at the end of every HTTP request.
+// By default, no finalizer is registered.
+func ServerFinalizer(f ...kit.ServerFinalizerFunc) ServerOption {
+	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
+}
+
+// ServerErrorHandler is used to handle non-terminal errors, such as the ones
+// returned by the methods. By default, non-terminal errors are ignored. This
+// is intended as a diagnostic measure.
+func ServerErrorHandler(errorHandler kit.ErrorHandler) ServerOption {
+	return func(s *Server) { s.errorHandler = errorHandler }
+}
+
+// NewServer constructs a new server, which implements http.Handler.
+func NewServer(options ...ServerOption) *Server {
+	s := &Server{
+		methods:      make(map[string]Method),
+		errorHandler: kit.ErrorHandlerFunc(kit.LogErrorHandler),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Register adds the method with the given name, replacing the previous one.
+// Methods must be registered before the server starts serving requests:
+//
+//	s.Register("greet", jsonrpc.NewMethod(greet, jsonrpc.DecodeParams[GreetRequest]))
+func (s *Server) Register(name string, m Method) {
+	s.methods[name] = m
+}
+
+// ServeHTTP implements http.Handler.
+func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if len(s.finalizer) > 0 {
+		iw := &interceptingWriter{w, http.StatusOK, 0}
+		defer func() {
+			ctx = context.WithValue(ctx, kit.ContextKeyResponseHeaders, iw.Header())
+			ctx = context.WithValue(ctx, kit.ContextKeyResponseSize, iw.written)
+			for _, f := range s.finalizer {
+				f(ctx, iw.code, r)
+			}
+		}()
+		w = iw
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, f := range s.before {
+		ctx = f(ctx, r)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.write(ctx, w, errorResponse(nil, &Error{Code: ParseError, Message: "Parse error"}))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		resp := s.call(ctx, body)
+		if resp == nil {
+			s.write(ctx, w, nil)
+			return
+		}
+		s.write(ctx, w, resp)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		s.write(ctx, w, errorResponse(nil, &Error{Code: ParseError, Message: "Parse error"}))
+		return
+	}
+	if len(batch) == 0 {
+		s.write(ctx, w, errorResponse(nil, &Error{Code: InvalidRequest, Message: "Invalid Request"}))
+		return
+	}
+
+	responses := make([]*Response, 0, len(batch))
+	for _, raw := range batch {
+		if resp := s.call(ctx, raw); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		s.write(ctx, w, nil)
+		return
+	}
+	s.write(ctx, w, responses)
+}
+
+// call processes a single request, it returns nil for notifications.
+func (s Server) call(ctx context.Context, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok || len(raw) == 0 {
+			return errorResponse(nil, &Error{Code: ParseError, Message: "Parse error"})
+		}
+		return errorResponse(nil, &Error{Code: InvalidRequest, Message: "Invalid Request"})
+	}
+
+	if !validRequest(req) {
+		return errorResponse(validID(req.ID), &Error{Code: InvalidRequest, Message: "Invalid Request"})
+	}
+	notification := req.ID == nil
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		if notification {
+			return nil
+		}
+		return errorResponse(req.ID, &Error{Code: MethodNotFound, Message: "Method not found"})
+	}
+
+	result, err := m(ctx, req.Params)
+	if err == nil {
+		var b []byte
+		if b, err = json.Marshal(result); err == nil {
+			if notification {
+				return nil
+			}
+			return &Response{JSONRPC: Version, Result: b, ID: req.ID}
+		}
+	}
+
+	s.errorHandler.Handle(ctx, err)
+	if notification {
+		return nil
+	}
+	return errorResponse(req.ID, toError(err))
+}
+
+// write runs the ServerAfter functions and writes the response, a nil
+// response is written as 204 No Content.
+func (s Server) write(ctx context.Context, w http.ResponseWriter, response any) {
+	for _, f := range s.after {
+		ctx = f(ctx, w)
+	}
+
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.errorHandler.Handle(ctx, err)
+	}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: Version, Error: err, ID: id}
+}
+
+func validRequest(req Request) bool {
+	if req.JSONRPC != Version || req.Method == "" {
+		return false
+	}
+	if len(req.Params) > 0 && req.Params[0] != '{' && req.Params[0] != '[' {
+		return false
+	}
+	return req.ID == nil || validID(req.ID) != nil
+}
+
+// validID returns the id when it's a string, a number or null.
+func validID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return nil
+	}
+	switch id[0] {
+	case '{', '[', 't', 'f':
+		return nil
+	}
+	return id
+}
+
+type interceptingWriter struct {
+	http.ResponseWriter
+	code    int
+	written int64
+}
+
+// WriteHeader may not be explicitly called, so care must be taken to
+// initialize w.code to its default value of http.StatusOK.
+func (w *interceptingWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *interceptingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,181 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+	"github.com/mishudark/kit/v2/jsonrpc"
+)
+
+type greetRequest struct {
+	Name string `json:"name" check:"required"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(_ context.Context, req greetRequest) (greetResponse, error) {
+	return greetResponse{Greeting: "hello " + req.Name}, nil
+}
+
+type quotaError struct{}
+
+func (quotaError) Error() string  { return "quota exceeded" }
+func (quotaError) ErrorCode() int { return -32000 }
+
+func TestServer(t *testing.T) {
+	var notified []string
+
+	s := jsonrpc.NewServer(
+		jsonrpc.ServerErrorHandler(kit.ErrorHandlerFunc(func(context.Context, error) {})),
+	)
+	s.Register("greet", jsonrpc.NewMethod(greet, jsonrpc.DecodeParams[greetRequest]))
+	s.Register("notify", jsonrpc.NewMethod(func(_ context.Context, req greetRequest) (struct{}, error) {
+		notified = append(notified, req.Name)
+		return struct{}{}, nil
+	}, jsonrpc.DecodeParams[greetRequest]))
+	s.Register("fail", func(context.Context, json.RawMessage) (any, error) {
+		return nil, quotaError{}
+	})
+
+	tc := [...]struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{
+			name: "call",
+			body: `{"jsonrpc":"2.0","method":"greet","params":{"name":"john"},"id":1}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","result":{"greeting":"hello john"},"id":1}`,
+		},
+		{
+			name: "null id",
+			body: `{"jsonrpc":"2.0","method":"greet","params":{"name":"john"},"id":null}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","result":{"greeting":"hello john"},"id":null}`,
+		},
+		{
+			name: "notification",
+			body: `{"jsonrpc":"2.0","method":"notify","params":{"name":"jane"}}`,
+			code: http.StatusNoContent,
+			want: ``,
+		},
+		{
+			name: "parse error",
+			body: `{"jsonrpc":"2.0","method"`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+		{
+			name: "invalid request",
+			body: `{"jsonrpc":"1.0","method":"greet","id":"a"}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":"a"}`,
+		},
+		{
+			name: "method not found",
+			body: `{"jsonrpc":"2.0","method":"missing","id":2}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2}`,
+		},
+		{
+			name: "invalid params",
+			body: `{"jsonrpc":"2.0","method":"greet","params":{},"id":3}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[{"field":"name","rule":"required","message":"is required"}]},"id":3}`,
+		},
+		{
+			name: "error coder",
+			body: `{"jsonrpc":"2.0","method":"fail","id":4}`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32000,"message":"quota exceeded"},"id":4}`,
+		},
+		{
+			name: "batch",
+			body: `[{"jsonrpc":"2.0","method":"greet","params":{"name":"a"},"id":1},{"jsonrpc":"2.0","method":"notify","params":{"name":"b"}},1]`,
+			code: http.StatusOK,
+			want: `[{"jsonrpc":"2.0","result":{"greeting":"hello a"},"id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`,
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			code: http.StatusOK,
+			want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			name: "batch of notifications",
+			body: `[{"jsonrpc":"2.0","method":"notify","params":{"name":"c"}}]`,
+			code: http.StatusNoContent,
+			want: ``,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body)))
+
+			if want, have := tt.code, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			if want, have := tt.want, strings.TrimSpace(rec.Body.String()); want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+		})
+	}
+
+	if want, have := "jane,b,c", strings.Join(notified, ","); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
+
+func TestServerHooks(t *testing.T) {
+	type userKey struct{}
+
+	var (
+		handled   error
+		finalCode int
+	)
+	s := jsonrpc.NewServer(
+		jsonrpc.ServerBefore(func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, userKey{}, r.Header.Get("X-User"))
+		}),
+		jsonrpc.ServerErrorHandler(kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		jsonrpc.ServerFinalizer(func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+	errDenied := errors.New("denied")
+	s.Register("whoami", jsonrpc.NewMethod(func(ctx context.Context, _ struct{}) (string, error) {
+		user, _ := ctx.Value(userKey{}).(string)
+		if user == "" {
+			return "", errDenied
+		}
+		return user, nil
+	}, jsonrpc.DecodeParams[struct{}]))
+
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"whoami","id":1}`))
+	req.Header.Set("X-User", "john")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if want, have := `{"jsonrpc":"2.0","result":"john","id":1}`, strings.TrimSpace(rec.Body.String()); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if want, have := http.StatusOK, finalCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"whoami","id":2}`)))
+	if !errors.Is(handled, errDenied) {
+		t.Errorf("want %v, have %v", errDenied, handled)
+	}
+}
@@ -0,0 +1,68 @@
+package kit
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/mishudark/errors"
+)
+
+// KindStatusCodes maps the kinds of github.com/mishudark/errors to HTTP
+// status codes.
+type KindStatusCodes map[errors.Kind]int
+
+// DefaultKindStatusCodes is the mapping used by KindErrorEncoder. It may be
+// modified at init time to change the status code of a kind for every
+// encoder relying on it.
+var DefaultKindStatusCodes = KindStatusCodes{
+	errors.Invalid:       http.StatusBadRequest,
+	errors.Permission:    http.StatusForbidden,
+	errors.Duplicated:    http.StatusConflict,
+	errors.NotExist:      http.StatusNotFound,
+	errors.Private:       http.StatusForbidden,
+	errors.Internal:      http.StatusInternalServerError,
+	errors.Decrypt:       http.StatusBadRequest,
+	errors.Unmarshal:     http.StatusBadRequest,
+	errors.Transient:     http.StatusServiceUnavailable,
+	errors.Unsupported:   http.StatusUnsupportedMediaType,
+	errors.NotAcceptable: http.StatusNotAcceptable,
+	errors.Timeout:       http.StatusGatewayTimeout,
+}
+
+// StatusCode returns the status code mapped to the kind of err. It reports
+// false when err doesn't wrap a *errors.Error or its kind isn't mapped.
+func (m KindStatusCodes) StatusCode(err error) (int, bool) {
+	var kindErr *errors.Error
+	if !stderrors.As(err, &kindErr) {
+		return 0, false
+	}
+
+	code, ok := m[kindErr.Kind]
+	return code, ok
+}
+
+// KindErrorEncoder works as DefaultErrorEncoder, but the status code is taken
+// from DefaultKindStatusCodes when the error has a github.com/mishudark/errors
+// kind. It means, for instance, that a malformed body rejected by
+// DecodeRequest with errors.Unmarshal is answered with a 400.
+func KindErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	NewKindErrorEncoder(DefaultKindStatusCodes)(ctx, err, w)
+}
+
+// NewKindErrorEncoder returns an ErrorEncoder that works as
+// DefaultErrorEncoder, but the status code is taken from the given mapping
+// when the kind of the error is found there. Otherwise, if the error
+// implements StatusCoder the provided StatusCode is used, falling back to 500.
+func NewKindErrorEncoder(codes KindStatusCodes) ErrorEncoder {
+	return func(_ context.Context, err error, w http.ResponseWriter) {
+		code, ok := codes.StatusCode(err)
+		if !ok {
+			code = http.StatusInternalServerError
+			if sc, ok := err.(StatusCoder); ok {
+				code = sc.StatusCode()
+			}
+		}
+		writeError(w, err, code)
+	}
+}
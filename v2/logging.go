@@ -0,0 +1,148 @@
+package kit
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/mishudark/kit/log"
+)
+
+// RequestLogger returns a RequestFunc that puts in the context a logger
+// bound to the request, see log.FromContext. The logger is enriched with the
+// request id, method, path and remote address, which are read from the
+// values populated by PopulateRequestContext, or from the request when they
+// are missing, so it should be registered after it. The request id is only
+// added when present.
+func RequestLogger(logger log.Logger) RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		keyvals := make([]any, 0, 8)
+		if id := contextString(ctx, ContextKeyRequestXRequestID, r.Header.Get(RequestIDHeader)); id != "" {
+			keyvals = append(keyvals, "request_id", id)
+		}
+		keyvals = append(keyvals,
+			"method", contextString(ctx, ContextKeyRequestMethod, r.Method),
+			"path", contextString(ctx, ContextKeyRequestPath, r.URL.Path),
+			"remote_addr", contextString(ctx, ContextKeyRequestRemoteAddr, r.RemoteAddr),
+		)
+		return log.NewContext(ctx, log.With(logger, keyvals...))
+	}
+}
+
+// Fields of the access log, see AccessLogFields.
+const (
+	AccessLogRequestID = "request_id"
+	AccessLogMethod    = "method"
+	AccessLogPath      = "path"
+	AccessLogStatus    = "status"
+	AccessLogBytes     = "bytes"
+	AccessLogDuration  = "duration"
+	AccessLogUserAgent = "user_agent"
+)
+
+// AccessLogOption sets an optional parameter for AccessLogFinalizer.
+type AccessLogOption func(*accessLog)
+
+type accessLog struct {
+	fields     map[string]bool
+	sampleRate float64
+}
+
+// AccessLogFields sets the fields written by the finalizer. By default,
+// every field is written. The selection doesn't apply to the fields already
+// bound to the context logger by RequestLogger, that is, the request id,
+// method and path, which are always part of its lines.
+func AccessLogFields(fields ...string) AccessLogOption {
+	return func(a *accessLog) {
+		a.fields = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			a.fields[f] = true
+		}
+	}
+}
+
+// AccessLogSampleSuccess logs only the given fraction, between 0 and 1, of
+// the successful requests, that is, the ones with a status code below 400.
+// Rates out of range are clamped. The other requests, including every 5xx,
+// are always logged. By default, every request is logged.
+func AccessLogSampleSuccess(rate float64) AccessLogOption {
+	return func(a *accessLog) {
+		switch {
+		case rate < 0:
+			rate = 0
+		case rate > 1:
+			rate = 1
+		}
+		a.sampleRate = rate
+	}
+}
+
+// RecordStartTime is a RequestFunc that puts the current time in the context
+// under ContextKeyRequestStartTime, it allows AccessLogFinalizer to log the
+// duration of the request. It should be the first RequestFunc.
+func RecordStartTime(ctx context.Context, _ *http.Request) context.Context {
+	return context.WithValue(ctx, ContextKeyRequestStartTime, time.Now())
+}
+
+// AccessLogFinalizer returns a ServerFinalizerFunc that logs a line per
+// request, with its method, path, status code, size of the response in
+// bytes, duration, user agent and request id. The duration is only logged
+// when RecordStartTime is registered with ServerBefore.
+//
+// The line is logged through the logger put in the context by
+// RequestLogger, which already carries the request id, method and path, so
+// the finalizer doesn't repeat them and AccessLogFields only selects among
+// the other fields. When the context carries no logger, the given one is
+// used and every field is subject to AccessLogFields.
+func AccessLogFinalizer(logger log.Logger, options ...AccessLogOption) ServerFinalizerFunc {
+	a := &accessLog{sampleRate: 1}
+	for _, option := range options {
+		option(a)
+	}
+
+	return func(ctx context.Context, code int, r *http.Request) {
+		if code < 400 && a.sampleRate < 1 && rand.Float64() >= a.sampleRate {
+			return
+		}
+
+		l, bound := log.FromContext(ctx)
+		if !bound {
+			l = logger
+		}
+
+		keyvals := []any{"msg", "access"}
+		add := func(field string, value any) {
+			if a.fields == nil || a.fields[field] {
+				keyvals = append(keyvals, field, value)
+			}
+		}
+
+		if !bound {
+			if id := contextString(ctx, ContextKeyRequestXRequestID, r.Header.Get(RequestIDHeader)); id != "" {
+				add(AccessLogRequestID, id)
+			}
+			add(AccessLogMethod, r.Method)
+			add(AccessLogPath, r.URL.Path)
+		}
+		add(AccessLogStatus, code)
+		if size, ok := ctx.Value(ContextKeyResponseSize).(int64); ok {
+			add(AccessLogBytes, size)
+		}
+		if start, ok := ctx.Value(ContextKeyRequestStartTime).(time.Time); ok {
+			add(AccessLogDuration, time.Since(start))
+		}
+		add(AccessLogUserAgent, r.UserAgent())
+
+		l.Log(keyvals...)
+	}
+}
+
+// contextString returns the string value of key, or fallback when the
+// context doesn't have it.
+func contextString(ctx context.Context, key contextKey, fallback string) string {
+	if v, ok := ctx.Value(key).(string); ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,89 @@
+package kit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit/log"
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+
+	handler := kit.NewServer(
+		func(ctx context.Context, _ testRequest) (testResponse, error) {
+			l, _ := log.FromContext(ctx)
+			l.Log("msg", "handling")
+			return testResponse{}, errors.New("boom")
+		},
+		kit.DecodeRequest[testRequest],
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerBefore[testRequest, testResponse](kit.PopulateRequestContext, kit.RequestLogger(logger)),
+		kit.ServerFinalizer[testRequest, testResponse](kit.AccessLogFinalizer(log.NewNopLogger())),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders?name=john", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	prefix := "request_id=abc method=GET path=/orders remote_addr=192.0.2.1:1234 "
+	want := []string{
+		prefix + "msg=handling",
+		prefix + "err=boom",
+		prefix + "msg=access status=500 bytes=4 user_agent=",
+	}
+	if want, have := strings.Join(want, "\n"), strings.TrimSpace(buf.String()); want != have {
+		t.Errorf("\nwant:\n%s\nhave:\n%s", want, have)
+	}
+}
+
+func TestAccessLogFinalizerSampling(t *testing.T) {
+	tc := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "success", want: ""},
+		{name: "server error", err: errors.New("boom"), want: "msg=access status=500 duration=ok\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := log.LoggerFunc(func(keyvals ...any) error {
+				for i := 0; i < len(keyvals)-1; i += 2 {
+					if _, ok := keyvals[i+1].(time.Duration); ok {
+						keyvals[i+1] = "ok"
+					}
+				}
+				return log.NewLogfmtLogger(&buf).Log(keyvals...)
+			})
+
+			handler := kit.NewServer(
+				func(context.Context, testRequest) (testResponse, error) { return testResponse{}, tt.err },
+				kit.DecodeRequest[testRequest],
+				kit.EncodeJSONResponse[testResponse],
+				kit.ServerBefore[testRequest, testResponse](kit.RecordStartTime),
+				kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+				kit.ServerFinalizer[testRequest, testResponse](kit.AccessLogFinalizer(logger,
+					kit.AccessLogSampleSuccess(0),
+					kit.AccessLogFields(kit.AccessLogStatus, kit.AccessLogDuration),
+				)),
+			)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders?name=john", nil))
+
+			if want, have := tt.want, buf.String(); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
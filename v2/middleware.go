@@ -0,0 +1,21 @@
+package kit
+
+// Middleware is a chainable behavior modifier for a HandlerFunc. It is the
+// place for cross-cutting concerns such as logging, timeouts, panic recovery
+// or metrics, which can then be reused across every Server.
+type Middleware[I, O any] func(HandlerFunc[I, O]) HandlerFunc[I, O]
+
+// Chain is a helper function for composing middlewares. Requests will
+// traverse them in the order they're declared. That is, the first middleware
+// is treated as the outermost middleware.
+// example:
+// h := Chain(logging, metrics)(handler)
+// // logging -> metrics -> handler -> metrics -> logging
+func Chain[I, O any](outer Middleware[I, O], others ...Middleware[I, O]) Middleware[I, O] {
+	return func(next HandlerFunc[I, O]) HandlerFunc[I, O] {
+		for i := len(others) - 1; i >= 0; i-- { // reverse
+			next = others[i](next)
+		}
+		return outer(next)
+	}
+}
@@ -0,0 +1,53 @@
+package kit_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+
+	annotate := func(name string) kit.Middleware[int, int] {
+		return func(next kit.HandlerFunc[int, int]) kit.HandlerFunc[int, int] {
+			return func(ctx context.Context, in int) (int, error) {
+				calls = append(calls, fmt.Sprintf("pre-%s", name))
+				out, err := next(ctx, in)
+				calls = append(calls, fmt.Sprintf("post-%s", name))
+				return out, err
+			}
+		}
+	}
+
+	h := kit.Chain(annotate("first"), annotate("second"), annotate("third"))(
+		func(_ context.Context, in int) (int, error) {
+			calls = append(calls, "handler")
+			return in * 2, nil
+		},
+	)
+
+	out, err := h(context.Background(), 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 42, out; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	want := []string{
+		"pre-first",
+		"pre-second",
+		"pre-third",
+		"handler",
+		"post-third",
+		"post-second",
+		"post-first",
+	}
+	if !reflect.DeepEqual(want, calls) {
+		t.Errorf("want %v, have %v", want, calls)
+	}
+}
@@ -0,0 +1,359 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"reflect"
+
+	"github.com/mishudark/errors"
+	"github.com/mishudark/magic"
+)
+
+const (
+	fileTag = "file"
+
+	// maxFormValueSize limits the size of each form value when the total
+	// size of the request is not limited.
+	maxFormValueSize = 10 << 20 // 10 MB
+)
+
+// DefaultMultipartMaxTotalSize is the size limit of all the parts of a
+// multipart request together, unless set with MultipartMaxTotalSize.
+const DefaultMultipartMaxTotalSize = 32 << 20 // 32 MB
+
+var (
+	fileType      = reflect.TypeOf((*File)(nil))
+	fileSliceType = reflect.TypeOf([]*File(nil))
+)
+
+// File is a file part of a multipart request. Its content is spooled to a
+// temporary file, which must be removed once the request is handled, see
+// RemoveFiles.
+type File struct {
+	// Filename is the name of the file sent by the client.
+	Filename string
+
+	// Header is the MIME header of the part.
+	Header textproto.MIMEHeader
+
+	// Size is the size of the content in bytes.
+	Size int64
+
+	path string
+}
+
+// Open returns a reader over the content of the file, the caller must close
+// it.
+func (f *File) Open() (io.ReadCloser, error) {
+	return os.Open(f.path)
+}
+
+// Remove deletes the temporary file, removing it twice is not an error.
+func (f *File) Remove() error {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MultipartOption sets an optional parameter for multipart decoders.
+type MultipartOption func(*multipartDecoder)
+
+type multipartDecoder struct {
+	maxFileSize  int64
+	maxTotalSize int64
+	tempDir      string
+	sources      []magic.Decoder
+}
+
+// MultipartMaxFileSize limits the size of each file, bigger files are
+// rejected with a *BodyTooLargeError. By default, the size is not limited.
+func MultipartMaxFileSize(n int64) MultipartOption {
+	return func(d *multipartDecoder) { d.maxFileSize = n }
+}
+
+// MultipartMaxTotalSize limits the size of all the parts together, bigger
+// requests are rejected with a *BodyTooLargeError. By default,
+// DefaultMultipartMaxTotalSize is used. A size of zero or less removes the
+// limit, each form value is then limited to 10 MB.
+func MultipartMaxTotalSize(n int64) MultipartOption {
+	return func(d *multipartDecoder) { d.maxTotalSize = n }
+}
+
+// MultipartTempDir sets the directory where files are spooled. By default,
+// os.TempDir is used.
+func MultipartTempDir(dir string) MultipartOption {
+	return func(d *multipartDecoder) { d.tempDir = dir }
+}
+
+// MultipartSources sets the sources applied once the multipart body is
+// decoded, replacing the default magic.ChiRouter.
+func MultipartSources(sources ...magic.Decoder) MultipartOption {
+	return func(d *multipartDecoder) { d.sources = sources }
+}
+
+// NewMultipartDecoder returns a DecodeRequestFunc for multipart/form-data
+// requests. The form values fill the fields tagged with form, while the file
+// parts are bound to the *File or []*File fields tagged with file:
+//
+//	type UploadRequest struct {
+//		Title  string  `form:"title"`
+//		Avatar *File   `file:"avatar"`
+//		Photos []*File `file:"photos"`
+//	}
+//
+// The parts are streamed, files are spooled to temporary files. Then the
+// decoded request is checked with Validate. The files bound to the request
+// are owned by the handler, which must remove them once done:
+//
+//	func upload(ctx context.Context, req UploadRequest) (UploadResponse, error) {
+//		defer kit.RemoveFiles(&req)
+//		...
+//	}
+//
+// The decoder removes the files itself when it fails, and so it does with
+// the files not bound to any field.
+func NewMultipartDecoder[I any](options ...MultipartOption) DecodeRequestFunc[I] {
+	d := &multipartDecoder{
+		maxTotalSize: DefaultMultipartMaxTotalSize,
+		sources:      []magic.Decoder{magic.ChiRouter},
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return func(_ context.Context, r *http.Request) (in I, err error) {
+		values, files, err := d.readParts(r)
+		if err != nil {
+			removeFiles(files)
+			if _, ok := err.(StatusCoder); ok {
+				return in, err
+			}
+			return in, errors.E(err, "can not unmarshal request", errors.Unmarshal)
+		}
+
+		var unbound []*File
+		if err = magic.ParseToStruct(formTag, values, &in); err == nil {
+			unbound, err = bindFiles(&in, files)
+		}
+		for _, source := range d.sources {
+			if err != nil {
+				break
+			}
+			err = magic.Decode(r, &in, source)
+		}
+		if err != nil {
+			removeFiles(files)
+			return in, errors.E(err, "can not unmarshal request", errors.Unmarshal)
+		}
+
+		if err = Validate(&in); err != nil {
+			removeFiles(files)
+			return in, err
+		}
+
+		for _, f := range unbound {
+			f.Remove()
+		}
+		return in, nil
+	}
+}
+
+// readParts streams the parts of the request, the values of the form are
+// kept in memory while files are spooled to disk.
+func (d *multipartDecoder) readParts(r *http.Request) (map[string]string, map[string][]*File, error) {
+	values := make(map[string]string)
+	files := make(map[string][]*File)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return values, files, nil
+		}
+		if err != nil {
+			return values, files, err
+		}
+
+		remaining := int64(-1)
+		if d.maxTotalSize > 0 {
+			remaining = d.maxTotalSize - total
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			limit, reported := int64(maxFormValueSize), int64(maxFormValueSize)
+			if remaining >= 0 && remaining < limit {
+				limit, reported = remaining, d.maxTotalSize
+			}
+			b, err := io.ReadAll(io.LimitReader(part, limit+1))
+			part.Close()
+			if err != nil {
+				return values, files, err
+			}
+			if int64(len(b)) > limit {
+				return values, files, &BodyTooLargeError{Limit: reported}
+			}
+			total += int64(len(b))
+			if _, ok := values[name]; !ok {
+				values[name] = string(b)
+			}
+			continue
+		}
+
+		limit, reported := int64(-1), int64(0)
+		if d.maxFileSize > 0 {
+			limit, reported = d.maxFileSize, d.maxFileSize
+		}
+		if remaining >= 0 && (limit < 0 || remaining < limit) {
+			limit, reported = remaining, d.maxTotalSize
+		}
+		f, err := d.spool(part, limit)
+		part.Close()
+		if f != nil {
+			files[name] = append(files[name], f)
+		}
+		if err == errPartTooLarge {
+			return values, files, &BodyTooLargeError{Limit: reported}
+		}
+		if err != nil {
+			return values, files, err
+		}
+		total += f.Size
+	}
+}
+
+var errPartTooLarge = errors.New("part too large")
+
+// spool copies the part into a temporary file, a negative limit means no
+// limit.
+func (d *multipartDecoder) spool(part *multipart.Part, limit int64) (*File, error) {
+	tmp, err := os.CreateTemp(d.tempDir, "kit-multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	f := &File{
+		Filename: part.FileName(),
+		Header:   part.Header,
+		path:     tmp.Name(),
+	}
+
+	var src io.Reader = part
+	if limit >= 0 {
+		src = io.LimitReader(part, limit+1)
+	}
+
+	f.Size, err = io.Copy(tmp, src)
+	if err != nil {
+		return f, err
+	}
+	if limit >= 0 && f.Size > limit {
+		return f, errPartTooLarge
+	}
+
+	return f, tmp.Close()
+}
+
+// bindFiles sets the *File and []*File fields of container tagged with file,
+// it returns the files left unbound.
+func bindFiles(container any, files map[string][]*File) ([]*File, error) {
+	v := reflect.ValueOf(container).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T must be a struct pointer", container)
+	}
+
+	bound := make(map[string]int, len(files))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get(fileTag)
+		if name == "" || name == "-" || len(files[name]) == 0 {
+			continue
+		}
+
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Type() {
+		case fileType:
+			field.Set(reflect.ValueOf(files[name][0]))
+			if bound[name] < 1 {
+				bound[name] = 1
+			}
+		case fileSliceType:
+			field.Set(reflect.ValueOf(files[name]))
+			bound[name] = len(files[name])
+		default:
+			return nil, fmt.Errorf("field %s tagged with file must be a *File or a []*File", t.Field(i).Name)
+		}
+	}
+
+	var unbound []*File
+	for name, fs := range files {
+		unbound = append(unbound, fs[bound[name]:]...)
+	}
+	return unbound, nil
+}
+
+// RemoveFiles removes the temporary files bound to the *File and []*File
+// fields of container, a request decoded by NewMultipartDecoder or a pointer
+// to it. The first error is returned, once every file is removed.
+func RemoveFiles(container any) error {
+	v := reflect.Indirect(reflect.ValueOf(container))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%T must be a struct or a struct pointer", container)
+	}
+
+	var files []*File
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get(fileTag); name == "" || name == "-" {
+			continue
+		}
+
+		f := v.Field(i)
+		if !f.CanInterface() {
+			continue
+		}
+
+		switch f.Type() {
+		case fileType:
+			files = append(files, f.Interface().(*File))
+		case fileSliceType:
+			files = append(files, f.Interface().([]*File)...)
+		}
+	}
+
+	var first error
+	for _, f := range files {
+		if f == nil {
+			continue
+		}
+		if err := f.Remove(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func removeFiles(files map[string][]*File) {
+	for _, fs := range files {
+		for _, f := range fs {
+			f.Remove()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package kit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+type uploadRequest struct {
+	Title  string      `form:"title" check:"required"`
+	Avatar *kit.File   `file:"avatar"`
+	Photos []*kit.File `file:"photos"`
+}
+
+func newMultipartRequest(t *testing.T, files map[string][]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("title", "holidays"); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		for _, content := range contents {
+			fw, err := mw.CreateFormFile(name, name+".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(fw, content)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func readFile(t *testing.T, f *kit.File) string {
+	t.Helper()
+
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMultipartDecoder(t *testing.T) {
+	dir := t.TempDir()
+	dec := kit.NewMultipartDecoder[uploadRequest](kit.MultipartTempDir(dir))
+
+	r := newMultipartRequest(t, map[string][]string{
+		"avatar": {"me"},
+		"photos": {"beach", "mountain"},
+		"stray":  {"unbound"},
+	})
+
+	req, err := dec(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "holidays", req.Title; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "me", readFile(t, req.Avatar); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "avatar.txt", req.Avatar.Filename; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := 2, len(req.Photos); want != have {
+		t.Fatalf("want %d photos, have %d", want, have)
+	}
+	if want, have := "mountain", readFile(t, req.Photos[1]); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// the unbound file is removed by the decoder, the others by the handler
+	if want, have := 3, countFiles(t, dir); want != have {
+		t.Errorf("want %d spooled files, have %d", want, have)
+	}
+	if err := kit.RemoveFiles(&req); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, countFiles(t, dir); want != have {
+		t.Errorf("want %d spooled files, have %d", want, have)
+	}
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestMultipartDecoderLimits(t *testing.T) {
+	tc := [...]struct {
+		name  string
+		opt   kit.MultipartOption
+		limit int64
+	}{
+		{
+			name:  "file size",
+			opt:   kit.MultipartMaxFileSize(4),
+			limit: 4,
+		},
+		{
+			name:  "total size",
+			opt:   kit.MultipartMaxTotalSize(16),
+			limit: 16,
+		},
+		{
+			name:  "default total size",
+			limit: kit.DefaultMultipartMaxTotalSize,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			options := []kit.MultipartOption{kit.MultipartTempDir(dir)}
+			if tt.opt != nil {
+				options = append(options, tt.opt)
+			}
+			dec := kit.NewMultipartDecoder[uploadRequest](options...)
+
+			picture := "a big picture of me"
+			if tt.opt == nil {
+				picture = strings.Repeat("x", kit.DefaultMultipartMaxTotalSize)
+			}
+			r := newMultipartRequest(t, map[string][]string{"avatar": {picture}})
+			_, err := dec(context.Background(), r)
+
+			tooLarge, ok := err.(*kit.BodyTooLargeError)
+			if !ok {
+				t.Fatalf("want *kit.BodyTooLargeError, have %v", err)
+			}
+			if want, have := tt.limit, tooLarge.Limit; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			if want, have := http.StatusRequestEntityTooLarge, tooLarge.StatusCode(); want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+
+			if want, have := 0, countFiles(t, dir); want != have {
+				t.Errorf("want %d spooled files, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestMultipartDecoderInvalidRequest(t *testing.T) {
+	type avatarRequest struct {
+		Owner  string    `form:"owner" check:"required"`
+		Avatar *kit.File `file:"avatar"`
+	}
+
+	dir := t.TempDir()
+	dec := kit.NewMultipartDecoder[avatarRequest](kit.MultipartTempDir(dir))
+
+	r := newMultipartRequest(t, map[string][]string{"avatar": {"me"}})
+	if _, err := dec(context.Background(), r); err == nil {
+		t.Fatal("want a validation error, have none")
+	}
+
+	// the files of a rejected request are removed by the decoder
+	if want, have := 0, countFiles(t, dir); want != have {
+		t.Errorf("want %d spooled files, have %d", want, have)
+	}
+}
@@ -0,0 +1,81 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultNDJSONFlushItems is the number of items written by
+// EncodeNDJSONResponse between flushes.
+const DefaultNDJSONFlushItems = 100
+
+// EncodeNDJSONResponse is a EncodeResponseFunc that writes the items as
+// newline delimited JSON, flushing every DefaultNDJSONFlushItems items. See
+// NewNDJSONEncoder.
+func EncodeNDJSONResponse[T any](ctx context.Context, w http.ResponseWriter, items Seq[T]) error {
+	return encodeNDJSON(ctx, w, items, DefaultNDJSONFlushItems)
+}
+
+// NewNDJSONEncoder returns a EncodeResponseFunc that writes the items as
+// newline delimited JSON (application/x-ndjson), one item per line, so big
+// result sets are never held in memory. The response is flushed every
+// flushEvery items and once the sequence is exhausted. An error yielded by
+// the sequence, or found while encoding an item, is written as a trailer
+// record {"error": "..."} and returned as a *StreamError, since the headers
+// were already sent. The number of items written is available to the
+// finalizers under ContextKeyResponseItems.
+func NewNDJSONEncoder[T any](flushEvery int) EncodeReponseFunc[Seq[T]] {
+	return func(ctx context.Context, w http.ResponseWriter, items Seq[T]) error {
+		return encodeNDJSON(ctx, w, items, flushEvery)
+	}
+}
+
+type errorRecord struct {
+	Error string `json:"error"`
+}
+
+func encodeNDJSON[T any](ctx context.Context, w http.ResponseWriter, items Seq[T], flushEvery int) error {
+	flush := func() {}
+	if flusher, ok := w.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	var (
+		enc     = json.NewEncoder(w)
+		pending int
+		failure error
+	)
+	items(func(item T, err error) bool {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = enc.Encode(item)
+		}
+		if err != nil {
+			failure = err
+			return false
+		}
+
+		addResponseItems(ctx, 1)
+		pending++
+		if flushEvery > 0 && pending >= flushEvery {
+			flush()
+			pending = 0
+		}
+		return true
+	})
+
+	if failure != nil {
+		enc.Encode(errorRecord{Error: failure.Error()})
+		flush()
+		return &StreamError{Err: failure}
+	}
+
+	flush()
+	return nil
+}
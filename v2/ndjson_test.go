@@ -0,0 +1,79 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestNDJSONEncoder(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tc := [...]struct {
+		name  string
+		items []testResponse
+		err   error
+		want  string
+	}{
+		{
+			name:  "complete",
+			items: []testResponse{{Greeting: "hi a"}, {Greeting: "hi b"}, {Greeting: "hi c"}},
+			want:  "{\"greeting\":\"hi a\"}\n{\"greeting\":\"hi b\"}\n{\"greeting\":\"hi c\"}\n",
+		},
+		{
+			name:  "failed",
+			items: []testResponse{{Greeting: "hi a"}},
+			err:   errBoom,
+			want:  "{\"greeting\":\"hi a\"}\n{\"error\":\"boom\"}\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				handled error
+				items   int64
+			)
+			handler := kit.NewServer(
+				func(ctx context.Context, _ testRequest) (kit.Seq[testResponse], error) {
+					return func(yield func(testResponse, error) bool) {
+						for _, item := range tt.items {
+							if !yield(item, nil) {
+								return
+							}
+						}
+						if tt.err != nil {
+							yield(testResponse{}, tt.err)
+						}
+					}, nil
+				},
+				kit.DecodeRequest[testRequest],
+				kit.NewNDJSONEncoder[testResponse](2),
+				kit.ServerErrorHandler[testRequest, kit.Seq[testResponse]](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+				kit.ServerFinalizer[testRequest, kit.Seq[testResponse]](func(ctx context.Context, _ int, _ *http.Request) {
+					items, _ = ctx.Value(kit.ContextKeyResponseItems).(int64)
+				}),
+			)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if want, have := "application/x-ndjson", rec.Header().Get("Content-Type"); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+			if want, have := tt.want, rec.Body.String(); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+			if want, have := int64(len(tt.items)), items; want != have {
+				t.Errorf("want %d items, have %d", want, have)
+			}
+			if !errors.Is(handled, tt.err) {
+				t.Errorf("want %v, have %v", tt.err, handled)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package kit
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mishudark/errors"
+)
+
+// MediaEncoder serializes a response into a specific media type.
+type MediaEncoder struct {
+	// MediaType is matched against the media ranges of the Accept header,
+	// e.g. application/json.
+	MediaType string
+
+	// ContentType is written in the Content-Type header, when empty the
+	// MediaType is used.
+	ContentType string
+
+	// Supports reports whether the value can be represented with the media
+	// type, a nil Supports accepts every value.
+	Supports func(v any) bool
+
+	// Encode writes v to w.
+	Encode func(w io.Writer, v any) error
+}
+
+func (m MediaEncoder) supports(v any) bool {
+	return m.Supports == nil || m.Supports(v)
+}
+
+func (m MediaEncoder) contentType() string {
+	if m.ContentType != "" {
+		return m.ContentType
+	}
+	return m.MediaType
+}
+
+// ProtoMarshaler is implemented by protobuf-like messages, which are able to
+// serialize themselves into their binary wire format.
+type ProtoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// MsgpMarshaler is implemented by MessagePack values, following the
+// convention of the code generated by github.com/tinylib/msgp.
+type MsgpMarshaler interface {
+	MarshalMsg([]byte) ([]byte, error)
+}
+
+var (
+	// JSONEncoder writes the response as application/json.
+	JSONEncoder = MediaEncoder{
+		MediaType:   "application/json",
+		ContentType: "application/json; charset=utf-8",
+		Encode: func(w io.Writer, v any) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	}
+
+	// XMLEncoder writes the response as application/xml.
+	XMLEncoder = MediaEncoder{
+		MediaType:   "application/xml",
+		ContentType: "application/xml; charset=utf-8",
+		Encode:      encodeXML,
+	}
+
+	// TextXMLEncoder writes the response as text/xml.
+	TextXMLEncoder = MediaEncoder{
+		MediaType:   "text/xml",
+		ContentType: "text/xml; charset=utf-8",
+		Encode:      encodeXML,
+	}
+
+	// MsgpackEncoder writes the response as application/msgpack, the
+	// response must implement MsgpMarshaler.
+	MsgpackEncoder = MediaEncoder{
+		MediaType: "application/msgpack",
+		Supports: func(v any) bool {
+			_, ok := v.(MsgpMarshaler)
+			return ok
+		},
+		Encode: func(w io.Writer, v any) error {
+			b, err := v.(MsgpMarshaler).MarshalMsg(nil)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		},
+	}
+
+	// ProtobufEncoder writes the response as application/x-protobuf, the
+	// response must implement ProtoMarshaler.
+	ProtobufEncoder = MediaEncoder{
+		MediaType: "application/x-protobuf",
+		Supports: func(v any) bool {
+			_, ok := v.(ProtoMarshaler)
+			return ok
+		},
+		Encode: func(w io.Writer, v any) error {
+			b, err := v.(ProtoMarshaler).Marshal()
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		},
+	}
+
+	// TextEncoder writes the response as text/plain, the response must be a
+	// string, a []byte, an encoding.TextMarshaler or a fmt.Stringer.
+	TextEncoder = MediaEncoder{
+		MediaType:   "text/plain",
+		ContentType: "text/plain; charset=utf-8",
+		Supports: func(v any) bool {
+			switch v.(type) {
+			case string, []byte, encoding.TextMarshaler, fmt.Stringer:
+				return true
+			}
+			return false
+		},
+		Encode: func(w io.Writer, v any) error {
+			var b []byte
+			switch t := v.(type) {
+			case string:
+				b = []byte(t)
+			case []byte:
+				b = t
+			case encoding.TextMarshaler:
+				text, err := t.MarshalText()
+				if err != nil {
+					return err
+				}
+				b = text
+			case fmt.Stringer:
+				b = []byte(t.String())
+			}
+			_, err := w.Write(b)
+			return err
+		},
+	}
+
+	// DefaultMediaEncoders are the encoders used by EncodeNegotiatedResponse,
+	// listed in order of preference.
+	DefaultMediaEncoders = []MediaEncoder{
+		JSONEncoder,
+		XMLEncoder,
+		TextXMLEncoder,
+		MsgpackEncoder,
+		ProtobufEncoder,
+		TextEncoder,
+	}
+)
+
+func encodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// EncodeNegotiatedResponse is a EncodeResponseFunc that serializes the
+// response with the DefaultMediaEncoders, picking the one that best matches
+// the Accept header. See NewNegotiatedEncoder.
+func EncodeNegotiatedResponse[O any](ctx context.Context, w http.ResponseWriter, response O) error {
+	return encodeNegotiated(ctx, w, response, DefaultMediaEncoders)
+}
+
+// NewNegotiatedEncoder returns a EncodeResponseFunc that serializes the
+// response with the encoder that best matches the Accept header, honoring
+// its q-values. The encoders are listed in order of preference, which is used
+// to break ties, the first one that supports the response is used when the
+// request has no Accept header. When none of the encoders is acceptable an
+// error of kind errors.NotAcceptable (406) is returned, so it can be written
+// by the ErrorEncoder.
+//
+// The Accept header is read from the context, so PopulateRequestContext must
+// be registered with ServerBefore. If the response implements Headerer, the
+// provided headers will be applied to the response. If the response
+// implements StatusCoder, the provided StatusCode will be used instead of 200.
+// Responses wrapped with AddStatusCode are unwrapped before being encoded.
+func NewNegotiatedEncoder[O any](encoders ...MediaEncoder) EncodeReponseFunc[O] {
+	return func(ctx context.Context, w http.ResponseWriter, response O) error {
+		return encodeNegotiated(ctx, w, response, encoders)
+	}
+}
+
+func encodeNegotiated(ctx context.Context, w http.ResponseWriter, response any, encoders []MediaEncoder) error {
+	accept, _ := ctx.Value(ContextKeyRequestAccept).(string)
+
+	// the media encoders serialize the wrapped response, not the wrapper
+	body := response
+	if rc, ok := response.(*ReponseCode); ok {
+		body = rc.resp
+	}
+
+	enc, ok := negotiate(accept, body, encoders)
+	if !ok {
+		err := errors.Errorf("can not produce any of the media types: %s", accept)
+		return errors.E(err, "can not encode response", errors.NotAcceptable)
+	}
+
+	w.Header().Set("Content-Type", enc.contentType())
+
+	if headerer, ok := response.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	code := http.StatusOK
+	if sc, ok := response.(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	w.WriteHeader(code)
+	if code == http.StatusNoContent {
+		return nil
+	}
+	return enc.Encode(w, body)
+}
+
+// mediaRange is a single element of an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses the media ranges of an Accept header, malformed ranges
+// are ignored.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := splitMediaType(params[0])
+		if !ok {
+			continue
+		}
+
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+				mr.q = q
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// negotiate returns the encoder that best matches the Accept header and
+// supports the response.
+func negotiate(accept string, response any, encoders []MediaEncoder) (MediaEncoder, bool) {
+	if strings.TrimSpace(accept) == "" {
+		for _, enc := range encoders {
+			if enc.supports(response) {
+				return enc, true
+			}
+		}
+		return MediaEncoder{}, false
+	}
+
+	ranges := parseAccept(accept)
+
+	var (
+		best   MediaEncoder
+		bestQ  float64
+		picked bool
+	)
+	for _, enc := range encoders {
+		if !enc.supports(response) {
+			continue
+		}
+		typ, subtype, ok := splitMediaType(enc.MediaType)
+		if !ok {
+			continue
+		}
+
+		// the most specific media range defines the quality of the encoder
+		var q float64
+		specificity := 0
+		for _, mr := range ranges {
+			var s int
+			switch {
+			case mr.typ == typ && mr.subtype == subtype:
+				s = 3
+			case mr.typ == typ && mr.subtype == "*":
+				s = 2
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 1
+			default:
+				continue
+			}
+			if s > specificity {
+				specificity, q = s, mr.q
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ, picked = enc, q, true
+		}
+	}
+
+	return best, picked
+}
+
+func splitMediaType(mediaType string) (typ, subtype string, ok bool) {
+	typ, subtype, ok = strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+	if !ok || typ == "" || subtype == "" {
+		return "", "", false
+	}
+	return typ, subtype, true
+}
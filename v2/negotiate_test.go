@@ -0,0 +1,127 @@
+package kit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestEncodeNegotiatedResponse(t *testing.T) {
+	tc := [...]struct {
+		name        string
+		accept      string
+		code        int
+		contentType string
+	}{
+		{
+			name:        "no accept header",
+			accept:      "",
+			code:        http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "xml",
+			accept:      "application/xml",
+			code:        http.StatusOK,
+			contentType: "application/xml; charset=utf-8",
+		},
+		{
+			name:        "q-values",
+			accept:      "application/json;q=0.5, text/xml;q=0.9, */*;q=0.1",
+			code:        http.StatusOK,
+			contentType: "text/xml; charset=utf-8",
+		},
+		{
+			name:        "wildcard",
+			accept:      "*/*",
+			code:        http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "unsupported value",
+			accept:      "application/x-protobuf",
+			code:        http.StatusNotAcceptable,
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "excluded media type",
+			accept:      "application/json;q=0, application/*;q=0",
+			code:        http.StatusNotAcceptable,
+			contentType: "application/json; charset=utf-8",
+		},
+	}
+
+	handler := kit.NewServer(
+		greet,
+		kit.DecodeRequest[testRequest],
+		kit.EncodeNegotiatedResponse[testResponse],
+		kit.ServerBefore[testRequest, testResponse](kit.PopulateRequestContext),
+	)
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?name=kit", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if want, have := tt.code, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			if want, have := tt.contentType, rec.Header().Get("Content-Type"); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+type textResponse string
+
+func (r textResponse) String() string { return string(r) }
+
+func TestNewNegotiatedEncoder(t *testing.T) {
+	enc := kit.NewNegotiatedEncoder[textResponse](kit.TextEncoder)
+
+	ctx := context.WithValue(context.Background(), kit.ContextKeyRequestAccept, "text/*")
+	rec := httptest.NewRecorder()
+	if err := enc(ctx, rec, textResponse("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "text/plain; charset=utf-8", rec.Header().Get("Content-Type"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "hello", rec.Body.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestEncodeNegotiatedResponseStatusCode(t *testing.T) {
+	handler := kit.NewServer(
+		func(ctx context.Context, req testRequest) (*kit.ReponseCode, error) {
+			resp, err := greet(ctx, req)
+			return kit.AddStatusCode(resp, http.StatusCreated), err
+		},
+		kit.DecodeRequest[testRequest],
+		kit.EncodeNegotiatedResponse[*kit.ReponseCode],
+		kit.ServerBefore[testRequest, *kit.ReponseCode](kit.PopulateRequestContext),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"kit"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/xml")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if want, have := http.StatusCreated, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := "<Greeting>hello kit</Greeting>", rec.Body.String(); !strings.Contains(have, want) {
+		t.Errorf("want %s in %s", want, have)
+	}
+}
@@ -0,0 +1,150 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/mishudark/errors"
+)
+
+// Problem is the RFC 7807 representation of an error, it's written by
+// ProblemErrorEncoder with the application/problem+json content type.
+type Problem struct {
+	// Type is a URI reference that identifies the problem type, about:blank
+	// when the problem has no additional semantics beyond the status code.
+	Type string `json:"type"`
+
+	// Title is a short, human-readable summary of the problem type.
+	Title string `json:"title"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+
+	// Detail is a human-readable explanation specific to this occurrence of
+	// the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is a URI reference that identifies the specific occurrence of
+	// the problem.
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions are additional members serialized next to the standard ones.
+	Extensions map[string]any `json:"-"`
+}
+
+var _ json.Marshaler = Problem{}
+
+// MarshalJSON serializes the standard members along with the extensions, the
+// standard members take precedence over the extensions with the same name.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	members, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return members, err
+	}
+
+	all := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		all[k] = v
+	}
+
+	var standard map[string]any
+	if err := json.Unmarshal(members, &standard); err != nil {
+		return nil, err
+	}
+	for k, v := range standard {
+		all[k] = v
+	}
+
+	return json.Marshal(all)
+}
+
+// ProblemErrorEncoder writes the error to the ResponseWriter as an RFC 7807
+// problem, using the application/problem+json content type.
+//
+// The status is taken from DefaultKindStatusCodes for the errors with a
+// github.com/mishudark/errors kind, from StatusCoder when the error implements
+// it, or defaults to 500. See NewProblemErrorEncoder.
+func ProblemErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	NewProblemErrorEncoder(DefaultKindStatusCodes)(ctx, err, w)
+}
+
+// NewProblemErrorEncoder returns an ErrorEncoder that writes the error as an
+// RFC 7807 problem, using the application/problem+json content type.
+//
+// The status is taken from the given mapping when the kind of the error is
+// found there, from StatusCoder when the error implements it, or defaults to
+// 500. Errors from github.com/mishudark/errors expose their friendly message
+// as the detail, along with their metadata as the "meta" member, while
+// ValidationErrors are listed in the "errors" member. If the error
+// implements Headerer, the provided headers will be applied to the response.
+// The instance is the request path and the request id is added as the
+// "request_id" member, both are read from the context values populated by
+// PopulateRequestContext.
+func NewProblemErrorEncoder(codes KindStatusCodes) ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		encodeProblem(ctx, err, w, codes)
+	}
+}
+
+func encodeProblem(ctx context.Context, err error, w http.ResponseWriter, codes KindStatusCodes) {
+	problem := Problem{
+		Type:   "about:blank",
+		Status: http.StatusInternalServerError,
+		Detail: err.Error(),
+	}
+
+	if code, ok := codes.StatusCode(err); ok {
+		problem.Status = code
+	} else if sc, ok := err.(StatusCoder); ok {
+		problem.Status = sc.StatusCode()
+	}
+
+	var kindErr *errors.Error
+	if stderrors.As(err, &kindErr) {
+		problem.Detail = kindErr.Msg()
+		if len(kindErr.Meta) > 0 {
+			problem.Extensions = map[string]any{"meta": kindErr.Meta}
+		}
+	}
+
+	var validationErrs ValidationErrors
+	if stderrors.As(err, &validationErrs) {
+		problem.Detail = "validation failed"
+		if problem.Extensions == nil {
+			problem.Extensions = make(map[string]any)
+		}
+		problem.Extensions["errors"] = []FieldError(validationErrs)
+	}
+
+	problem.Title = http.StatusText(problem.Status)
+
+	if path, ok := ctx.Value(ContextKeyRequestPath).(string); ok {
+		problem.Instance = path
+	}
+	if id, ok := ctx.Value(ContextKeyRequestXRequestID).(string); ok && id != "" {
+		if problem.Extensions == nil {
+			problem.Extensions = make(map[string]any)
+		}
+		problem.Extensions["request_id"] = id
+	}
+
+	body, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		DefaultErrorEncoder(ctx, err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	if headerer, ok := err.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
@@ -0,0 +1,101 @@
+package kit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mishudark/errors"
+	kit "github.com/mishudark/kit/v2"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string { return "short and stout" }
+
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+func (teapotError) Headers() http.Header { return http.Header{"X-Tea": []string{"earl grey"}} }
+
+func TestProblemErrorEncoder(t *testing.T) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, kit.ContextKeyRequestPath, "/orders/1")
+	ctx = context.WithValue(ctx, kit.ContextKeyRequestXRequestID, "req-1")
+
+	tc := [...]struct {
+		name    string
+		err     error
+		problem map[string]any
+		header  string
+	}{
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			problem: map[string]any{
+				"type":       "about:blank",
+				"title":      "Internal Server Error",
+				"status":     float64(500),
+				"detail":     "boom",
+				"instance":   "/orders/1",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name: "error kind",
+			err:  errors.E(errors.New("unexpected EOF"), "can not unmarshal request", errors.Unmarshal),
+			problem: map[string]any{
+				"type":       "about:blank",
+				"title":      "Bad Request",
+				"status":     float64(400),
+				"detail":     "can not unmarshal request",
+				"instance":   "/orders/1",
+				"request_id": "req-1",
+			},
+		},
+		{
+			name: "status coder and headerer",
+			err:  teapotError{},
+			problem: map[string]any{
+				"type":       "about:blank",
+				"title":      "I'm a teapot",
+				"status":     float64(418),
+				"detail":     "short and stout",
+				"instance":   "/orders/1",
+				"request_id": "req-1",
+			},
+			header: "earl grey",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			kit.ProblemErrorEncoder(ctx, tt.err, rec)
+
+			if want, have := "application/problem+json", rec.Header().Get("Content-Type"); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+			if want, have := int(tt.problem["status"].(float64)), rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			if want, have := tt.header, rec.Header().Get("X-Tea"); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+
+			var have map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &have); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.problem {
+				if have[k] != want {
+					t.Errorf("%s: want %v, have %v", k, want, have[k])
+				}
+			}
+			if want, have := len(tt.problem), len(have); want != have {
+				t.Errorf("want %d members, have %d: %v", want, have, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoveryHandler turns the value recovered from a panic into an error. The
+// error is then passed to the ErrorHandler and encoded with the ErrorEncoder
+// as any other error.
+type RecoveryHandler func(ctx context.Context, p any) error
+
+// PanicError is returned by DefaultRecoveryHandler, it carries the recovered
+// value and the stack trace of the goroutine that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// StatusCode implements StatusCoder, a panic is always an internal error.
+func (e *PanicError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// DefaultRecoveryHandler wraps the recovered value and the current stack in a
+// PanicError.
+func DefaultRecoveryHandler(_ context.Context, p any) error {
+	return &PanicError{
+		Value: p,
+		Stack: debug.Stack(),
+	}
+}
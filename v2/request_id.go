@@ -0,0 +1,120 @@
+package kit
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mishudark/kit/log"
+)
+
+// RequestIDHeader is the header carrying the id of a request, in both the
+// request and the response.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength limits the size of the incoming request ids.
+const maxRequestIDLength = 128
+
+// IDGenerator returns a new, unique, request id.
+type IDGenerator func() string
+
+// NewUUID is an IDGenerator returning a random, version 4, UUID.
+func NewUUID() string {
+	var b [16]byte
+	randomBytes(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// crockford is the base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID is an IDGenerator returning a ULID, the ids are sortable by the
+// millisecond they were generated at.
+func NewULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	randomBytes(b[6:])
+
+	// the 128 bits are encoded in 26 characters of 5 bits, padded with 2
+	// leading zero bits
+	var id [26]byte
+	for i := range id {
+		var n byte
+		for j := i*5 - 2; j < i*5+3; j++ {
+			n <<= 1
+			if j >= 0 && b[j/8]&(0x80>>(j%8)) != 0 {
+				n |= 1
+			}
+		}
+		id[i] = crockford[n]
+	}
+
+	return string(id[:])
+}
+
+// randomBytes fills b from crypto/rand, which never fails on the supported
+// platforms.
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("kit: can not read random bytes: %v", err))
+	}
+}
+
+// RequestID returns a RequestFunc that puts the id of the request in the
+// context under ContextKeyRequestXRequestID. The id sent by the client in the
+// RequestIDHeader is kept, as long as it's at most 128 printable ASCII
+// characters, otherwise a new one is generated with gen, NewUUID when nil.
+// Use ServerRequestID to also send the id back to the client.
+func RequestID(gen IDGenerator) RequestFunc {
+	if gen == nil {
+		gen = NewUUID
+	}
+
+	return func(ctx context.Context, r *http.Request) context.Context {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = gen()
+		}
+		return context.WithValue(ctx, ContextKeyRequestXRequestID, id)
+	}
+}
+
+// ServerRequestID sets the id of every request as RequestID does, before the
+// ServerBefore functions, and writes it in the RequestIDHeader of the
+// response, errors and recovered panics included.
+func ServerRequestID[I, O any](gen IDGenerator) ServerOption[I, O] {
+	return func(s *Server[I, O]) { s.requestID = RequestID(gen) }
+}
+
+// validRequestID reports whether id can be used as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDValuer returns a log.Valuer with the id of the request carried by
+// ctx, or an empty string. Loggers bound to it log the same id as the
+// responses and errors of the request:
+//
+//	logger = log.With(logger, "request_id", kit.RequestIDValuer(ctx))
+func RequestIDValuer(ctx context.Context) log.Valuer {
+	return func() any {
+		id, _ := ctx.Value(ContextKeyRequestXRequestID).(string)
+		return id
+	}
+}
@@ -0,0 +1,58 @@
+package kit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+	var upstreamID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get(kit.RequestIDHeader)
+		w.Write([]byte(`{"greeting":"hello"}`))
+	}))
+	defer upstream.Close()
+
+	tgt, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := kit.NewClient(
+		http.MethodPost,
+		tgt,
+		kit.EncodeJSONRequest[testRequest],
+		kit.DecodeJSONResponse[testResponse],
+	).Endpoint()
+
+	handler := kit.NewServer(
+		client,
+		kit.DecodeRequest[testRequest],
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerRequestID[testRequest, testResponse](kit.NewULID),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/?name=kit", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	id := rec.Header().Get(kit.RequestIDHeader)
+	if want, have := 26, len(id); want != have {
+		t.Fatalf("want an id of %d characters, have %q", want, id)
+	}
+	if want, have := id, upstreamID; want != have {
+		t.Errorf("want %q forwarded, have %q", want, have)
+	}
+}
+
+func TestRequestIDValuer(t *testing.T) {
+	ctx := context.WithValue(context.Background(), kit.ContextKeyRequestXRequestID, "abc")
+
+	if want, have := "abc", kit.RequestIDValuer(ctx)(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
@@ -36,6 +36,10 @@ func SetRequestHeader(key, val string) RequestFunc {
 // the context from the HTTP request. Those values may be extracted using the
 // corresponding ContextKey type in this package.
 func PopulateRequestContext(ctx context.Context, r *http.Request) context.Context {
+	requestID := r.Header.Get("X-Request-Id")
+	if id, ok := ctx.Value(ContextKeyRequestXRequestID).(string); ok && id != "" {
+		requestID = id
+	}
 	for k, v := range map[contextKey]string{
 		ContextKeyRequestMethod:          r.Method,
 		ContextKeyRequestURI:             r.RequestURI,
@@ -48,11 +52,15 @@ func PopulateRequestContext(ctx context.Context, r *http.Request) context.Contex
 		ContextKeyRequestAuthorization:   r.Header.Get("Authorization"),
 		ContextKeyRequestReferer:         r.Header.Get("Referer"),
 		ContextKeyRequestUserAgent:       r.Header.Get("User-Agent"),
-		ContextKeyRequestXRequestID:      r.Header.Get("X-Request-Id"),
+		ContextKeyRequestXRequestID:      requestID,
 		ContextKeyRequestAccept:          r.Header.Get("Accept"),
+		ContextKeyRequestLastEventID:     r.Header.Get("Last-Event-ID"),
 	} {
 		ctx = context.WithValue(ctx, k, v)
 	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		ctx = context.WithValue(ctx, ContextKeyRequestPeerCertificate, r.TLS.VerifiedChains[0][0])
+	}
 	return ctx
 }
 
@@ -104,7 +112,9 @@ const (
 	ContextKeyRequestUserAgent
 
 	// ContextKeyRequestXRequestID is populated in the context by
-	// PopulateRequestContext. Its value is r.Header.Get("X-Request-Id").
+	// PopulateRequestContext and RequestID. Its value is
+	// r.Header.Get("X-Request-Id"), unless the id was already set by
+	// RequestID.
 	ContextKeyRequestXRequestID
 
 	// ContextKeyRequestAccept is populated in the context by
@@ -119,4 +129,27 @@ const (
 	// ContextKeyResponseSize is populated in the context whenever a
 	// ServerFinalizerFunc is specified. Its value is of type int64.
 	ContextKeyResponseSize
+
+	// ContextKeyRequestLastEventID is populated in the context by
+	// PopulateRequestContext and NewEventStreamServer. Its value is
+	// r.Header.Get("Last-Event-ID").
+	ContextKeyRequestLastEventID
+
+	// ContextKeyResponseItems is populated in the context whenever a
+	// ServerFinalizerFunc is specified. Its value is of type int64, the
+	// number of items written by a streaming encoder such as
+	// EncodeNDJSONResponse or EncodeEventStream, or the number of messages
+	// sent by a WebSocketServer.
+	ContextKeyResponseItems
+
+	// ContextKeyRequestPeerCertificate is populated in the context by
+	// PopulateRequestContext when the client presented a certificate which
+	// was verified, see serverutil.WithClientCAs. Its value is of type
+	// *x509.Certificate, the leaf of the first verified chain.
+	ContextKeyRequestPeerCertificate
+
+	// ContextKeyRequestStartTime is populated in the context by
+	// RecordStartTime. Its value is of type time.Time, the time the request
+	// started to be processed.
+	ContextKeyRequestStartTime
 )
@@ -3,12 +3,16 @@ package kit
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 // RequestFunc may take information from an HTTP request and put it into a
 // request context. In Servers, RequestFuncs are executed prior to invoking the
-// endpoint.
+// endpoint. In Clients, RequestFuncs are executed after creating the request
+// but prior to invoking the HTTP client.
 type RequestFunc func(context.Context, *http.Request) context.Context
 
 // ErrorEncoder is responsible for encoding an error to the ResponseWriter.
@@ -53,6 +57,16 @@ type HandlerFunc[I, O any] func(context.Context, I) (resp O, err error)
 // encoded form of the error will be used. If the error implements StatusCoder,
 // the provided StatusCode will be used instead of 500.
 func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	code := http.StatusInternalServerError
+	if sc, ok := err.(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	writeError(w, err, code)
+}
+
+// writeError writes the error with the given status code, as described by
+// DefaultErrorEncoder.
+func writeError(w http.ResponseWriter, err error, code int) {
 	contentType, body := "text/plain; charset=utf-8", []byte(err.Error())
 	if marshaler, ok := err.(json.Marshaler); ok {
 		if jsonBody, marshalErr := marshaler.MarshalJSON(); marshalErr == nil {
@@ -67,10 +81,6 @@ func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 			}
 		}
 	}
-	code := http.StatusInternalServerError
-	if sc, ok := err.(StatusCoder); ok {
-		code = sc.StatusCode()
-	}
 	w.WriteHeader(code)
 	w.Write(body)
 }
@@ -128,6 +138,48 @@ type Server[I, O any] struct {
 	errorEncoder ErrorEncoder
 	finalizer    []ServerFinalizerFunc
 	errorHandler ErrorHandler
+	stageErrors  map[Stage]ErrorEncoder
+	recovery     RecoveryHandler
+
+	timeout       time.Duration
+	timeoutHeader string
+	requestID     RequestFunc
+}
+
+// Stage identifies the step of the request pipeline where an error was
+// encountered.
+type Stage int
+
+const (
+	// StageDecode is the step where the DecodeRequestFunc is invoked.
+	StageDecode Stage = iota
+
+	// StageHandler is the step where the HandlerFunc is invoked.
+	StageHandler
+
+	// StageEncode is the step where the EncodeReponseFunc is invoked.
+	StageEncode
+)
+
+// ServerErrorEncoder is used to encode errors to the http.ResponseWriter
+// whenever they're encountered in the processing of a request. Clients can
+// use this to provide custom error formatting and response codes. By default,
+// errors will be written with the DefaultErrorEncoder.
+func ServerErrorEncoder[I, O any](ee ErrorEncoder) ServerOption[I, O] {
+	return func(s *Server[I, O]) { s.errorEncoder = ee }
+}
+
+// ServerStageErrorEncoder is used to encode the errors encountered in the
+// given stage, overriding the ServerErrorEncoder for that stage only. It
+// allows, for instance, to answer with a 400 to the errors returned by the
+// decoder and with a 500 to the ones returned by the handler.
+func ServerStageErrorEncoder[I, O any](stage Stage, ee ErrorEncoder) ServerOption[I, O] {
+	return func(s *Server[I, O]) {
+		if s.stageErrors == nil {
+			s.stageErrors = make(map[Stage]ErrorEncoder)
+		}
+		s.stageErrors[stage] = ee
+	}
 }
 
 // ServerErrorHandler is used to handle non-terminal errors. By default, non-terminal errors
@@ -145,39 +197,93 @@ func ServerFinalizer[I, O any](f ...ServerFinalizerFunc) ServerOption[I, O] {
 	return func(s *Server[I, O]) { s.finalizer = append(s.finalizer, f...) }
 }
 
+// ServerRecover recovers from panics raised while processing a request, the
+// RecoveryHandler turns the panic into an error which is passed to the
+// ErrorHandler and written with the ErrorEncoder. Finalizers observe the
+// status code written by the ErrorEncoder. When the response was already
+// committed, the error is only passed to the ErrorHandler and finalizers
+// observe the committed status code. By default, panics are not recovered.
+func ServerRecover[I, O any](rh RecoveryHandler) ServerOption[I, O] {
+	return func(s *Server[I, O]) { s.recovery = rh }
+}
+
 // ServeHTTP implements http.Handler.
 func (s Server[I, O]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	// the writer tracks whether the response was committed for the recovery
+	var iw *interceptingWriter
+	if len(s.finalizer) > 0 || s.recovery != nil {
+		iw = &interceptingWriter{ResponseWriter: w, code: http.StatusOK}
+		w = iw
+	}
+
 	if len(s.finalizer) > 0 {
-		iw := &interceptingWriter{w, http.StatusOK, 0}
+		items := new(int64)
+		ctx = context.WithValue(ctx, responseItemsKey{}, items)
 		defer func() {
 			ctx = context.WithValue(ctx, ContextKeyResponseHeaders, iw.Header())
 			ctx = context.WithValue(ctx, ContextKeyResponseSize, iw.written)
+			ctx = context.WithValue(ctx, ContextKeyResponseItems, atomic.LoadInt64(items))
 			for _, f := range s.finalizer {
 				f(ctx, iw.code, r)
 			}
 		}()
-		w = iw
+	}
+
+	if s.recovery != nil {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			err := s.recovery(ctx, p)
+			s.errorHandler.Handle(ctx, err)
+			if !iw.wroteHeader {
+				s.errorEncoder(ctx, err, w)
+			}
+		}()
+	}
+
+	if s.requestID != nil {
+		ctx = s.requestID(ctx, r)
+		id, _ := ctx.Value(ContextKeyRequestXRequestID).(string)
+		w.Header().Set(RequestIDHeader, id)
 	}
 
 	for _, f := range s.before {
 		ctx = f(ctx, r)
 	}
 
-	req, err := s.dec(ctx, r)
-	if err != nil {
-		s.errorHandler.Handle(ctx, err)
-		s.errorEncoder(ctx, err, w)
-		return
+	// the deadline only bounds the decoder and the handler
+	base := ctx
+	var timeout time.Duration
+	if s.timeout > 0 {
+		timeout = requestTimeout(r, s.timeout, s.timeoutHeader)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	resp, err := s.h(ctx, req)
+	// the stage is read once the call returned or was abandoned
+	stage := int32(StageDecode)
+	resp, err := awaitDeadline(ctx, base, timeout, func() (O, error) {
+		req, err := s.dec(ctx, r)
+		if err != nil {
+			var zero O
+			return zero, err
+		}
+		atomic.StoreInt32(&stage, int32(StageHandler))
+		return s.h(ctx, req)
+	})
 	if err != nil {
-		s.errorHandler.Handle(ctx, err)
-		s.errorEncoder(ctx, err, w)
+		s.handleError(ctx, Stage(atomic.LoadInt32(&stage)), err, w)
 		return
 	}
+	ctx = base
 
 	for _, f := range s.after {
 		ctx = f(ctx, w)
@@ -185,11 +291,39 @@ func (s Server[I, O]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	err = s.enc(ctx, w, resp)
 	if err != nil {
-		s.errorHandler.Handle(ctx, err)
-		s.errorEncoder(ctx, err, w)
+		var streamErr *StreamError
+		if stderrors.As(err, &streamErr) {
+			// the response is already committed
+			s.errorHandler.Handle(ctx, err)
+			return
+		}
+		s.handleError(ctx, StageEncode, err, w)
 	}
 }
 
+// responseItemsKey holds the counter of the items written by the streaming
+// encoders, it's reported to finalizers under ContextKeyResponseItems.
+type responseItemsKey struct{}
+
+// addResponseItems adds n to the count of items written in the response.
+func addResponseItems(ctx context.Context, n int64) {
+	if items, ok := ctx.Value(responseItemsKey{}).(*int64); ok {
+		atomic.AddInt64(items, n)
+	}
+}
+
+// handleError passes err to the ErrorHandler and encodes it with the
+// ErrorEncoder registered for the given stage, if any.
+func (s Server[I, O]) handleError(ctx context.Context, stage Stage, err error, w http.ResponseWriter) {
+	s.errorHandler.Handle(ctx, err)
+
+	ee := s.errorEncoder
+	if stageEncoder, ok := s.stageErrors[stage]; ok {
+		ee = stageEncoder
+	}
+	ee(ctx, err, w)
+}
+
 // ServerOption sets an optional parameter for servers.
 type ServerOption[I, O any] func(*Server[I, O])
 
@@ -238,23 +372,43 @@ func NewServer[I, O any](
 
 type interceptingWriter struct {
 	http.ResponseWriter
-	code    int
-	written int64
+	code        int
+	written     int64
+	wroteHeader bool
 }
 
 // WriteHeader may not be explicitly called, so care must be taken to
 // initialize w.code to its default value of http.StatusOK.
 func (w *interceptingWriter) WriteHeader(code int) {
-	w.code = code
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = code >= 200 // informational responses are not final
+	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
 func (w *interceptingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
 	n, err := w.ResponseWriter.Write(p)
 	w.written += int64(n)
 	return n, err
 }
 
+// Flush implements http.Flusher, so streaming responses keep working when
+// finalizers are registered.
+func (w *interceptingWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, it's used by
+// http.ResponseController.
+func (w *interceptingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 var _ StatusCoder = (*ReponseCode)(nil)
 var _ json.Marshaler = (*ReponseCode)(nil)
 
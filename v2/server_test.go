@@ -0,0 +1,319 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+type testRequest struct {
+	Name string `json:"name"`
+}
+
+type testResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(_ context.Context, req testRequest) (testResponse, error) {
+	return testResponse{Greeting: "hello " + req.Name}, nil
+}
+
+func statusEncoder(code int) kit.ErrorEncoder {
+	return func(_ context.Context, _ error, w http.ResponseWriter) {
+		w.WriteHeader(code)
+	}
+}
+
+func TestServerErrorEncoder(t *testing.T) {
+	errBoom := errors.New("boom")
+	handler := kit.NewServer(
+		func(context.Context, testRequest) (testResponse, error) { return testResponse{}, errBoom },
+		kit.DecodeRequest[testRequest],
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerErrorEncoder[testRequest, testResponse](statusEncoder(http.StatusTeapot)),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want, have := http.StatusTeapot, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestServerStageErrorEncoder(t *testing.T) {
+	errDecode := errors.New("decode")
+	errHandler := errors.New("handler")
+	errEncode := errors.New("encode")
+
+	tc := [...]struct {
+		name string
+		dec  kit.DecodeRequestFunc[testRequest]
+		h    kit.HandlerFunc[testRequest, testResponse]
+		enc  kit.EncodeReponseFunc[testResponse]
+		code int
+	}{
+		{
+			name: "decode",
+			dec:  func(context.Context, *http.Request) (testRequest, error) { return testRequest{}, errDecode },
+			h:    greet,
+			enc:  kit.EncodeJSONResponse[testResponse],
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "handler",
+			dec:  kit.DecodeRequest[testRequest],
+			h:    func(context.Context, testRequest) (testResponse, error) { return testResponse{}, errHandler },
+			enc:  kit.EncodeJSONResponse[testResponse],
+			code: http.StatusInternalServerError,
+		},
+		{
+			name: "encode",
+			dec:  kit.DecodeRequest[testRequest],
+			h:    greet,
+			enc:  func(context.Context, http.ResponseWriter, testResponse) error { return errEncode },
+			code: http.StatusTeapot,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := kit.NewServer(tt.h, tt.dec, tt.enc,
+				kit.ServerErrorEncoder[testRequest, testResponse](statusEncoder(http.StatusTeapot)),
+				kit.ServerStageErrorEncoder[testRequest, testResponse](kit.StageDecode, statusEncoder(http.StatusBadRequest)),
+				kit.ServerStageErrorEncoder[testRequest, testResponse](kit.StageHandler, statusEncoder(http.StatusInternalServerError)),
+			)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?name=kit", nil))
+			if want, have := tt.code, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestServerRecover(t *testing.T) {
+	var (
+		handled   error
+		finalCode int
+	)
+	handler := kit.NewServer(
+		greet,
+		func(context.Context, *http.Request) (testRequest, error) { panic("boom") },
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerRecover[testRequest, testResponse](kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		kit.ServerFinalizer[testRequest, testResponse](func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want, have := http.StatusInternalServerError, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := http.StatusInternalServerError, finalCode; want != have {
+		t.Errorf("finalizer: want %d, have %d", want, have)
+	}
+
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Fatalf("want *kit.PanicError, have %T", handled)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("want a stack trace, have none")
+	}
+}
+
+func TestServerRecoverCommitted(t *testing.T) {
+	var (
+		handled   error
+		finalCode int
+	)
+	handler := kit.NewServer(
+		greet,
+		kit.DecodeRequest[testRequest],
+		func(_ context.Context, w http.ResponseWriter, _ testResponse) error {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			panic("boom")
+		},
+		kit.ServerRecover[testRequest, testResponse](kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		kit.ServerFinalizer[testRequest, testResponse](func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?name=john", nil))
+
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := "partial", rec.Body.String(); want != have {
+		t.Errorf("want body %q, have %q", want, have)
+	}
+	if want, have := http.StatusOK, finalCode; want != have {
+		t.Errorf("finalizer: want %d, have %d", want, have)
+	}
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Errorf("want *kit.PanicError, have %T", handled)
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	slow := func(ctx context.Context, req testRequest) (testResponse, error) {
+		select {
+		case <-ctx.Done():
+			return testResponse{}, ctx.Err()
+		case <-time.After(time.Second):
+			return greet(ctx, req)
+		}
+	}
+
+	tc := [...]struct {
+		name    string
+		timeout time.Duration
+		header  string
+		want    int
+	}{
+		{name: "deadline exceeded", timeout: 10 * time.Millisecond, want: http.StatusGatewayTimeout},
+		{name: "shortened by header", timeout: time.Minute, header: "10ms", want: http.StatusGatewayTimeout},
+		{name: "header can not extend", timeout: 10 * time.Millisecond, header: "60", want: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled error
+			handler := kit.NewServer(
+				slow,
+				kit.DecodeRequest[testRequest],
+				kit.EncodeJSONResponse[testResponse],
+				kit.ServerTimeout[testRequest, testResponse](tt.timeout),
+				kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/?name=john", nil)
+			req.Header.Set(kit.DefaultTimeoutHeader, tt.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, have := tt.want, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			var timeoutErr *kit.TimeoutError
+			if !errors.As(handled, &timeoutErr) {
+				t.Errorf("want *kit.TimeoutError, have %v", handled)
+			}
+		})
+	}
+}
+
+func TestServerTimeoutNotExceeded(t *testing.T) {
+	handler := kit.NewServer(
+		greet,
+		kit.DecodeRequest[testRequest],
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerTimeout[testRequest, testResponse](time.Minute),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?name=john", nil))
+
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestServerTimeoutLateResult(t *testing.T) {
+	// the handler ignoring its context is released once the response is written
+	release := make(chan struct{})
+	defer close(release)
+
+	tc := [...]struct {
+		name    string
+		timeout time.Duration
+		parent  time.Duration
+		handler kit.HandlerFunc[testRequest, testResponse]
+		want    int
+	}{
+		{
+			name:    "success past the deadline",
+			timeout: 10 * time.Millisecond,
+			handler: func(ctx context.Context, req testRequest) (testResponse, error) {
+				<-release
+				return greet(ctx, req)
+			},
+			want: http.StatusGatewayTimeout,
+		},
+		{
+			name:    "inherited deadline",
+			timeout: time.Minute,
+			parent:  10 * time.Millisecond,
+			handler: func(ctx context.Context, _ testRequest) (testResponse, error) {
+				<-ctx.Done()
+				return testResponse{}, ctx.Err()
+			},
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled error
+			handler := kit.NewServer(
+				tt.handler,
+				kit.DecodeRequest[testRequest],
+				kit.EncodeJSONResponse[testResponse],
+				kit.ServerTimeout[testRequest, testResponse](tt.timeout),
+				kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/?name=john", nil)
+			if tt.parent > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), tt.parent)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, have := tt.want, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+			var timeoutErr *kit.TimeoutError
+			if want, have := tt.want == http.StatusGatewayTimeout, errors.As(handled, &timeoutErr); want != have {
+				t.Errorf("want timeout error %v, have %v", want, handled)
+			}
+		})
+	}
+}
+
+func TestServerTimeoutRecover(t *testing.T) {
+	var handled error
+	handler := kit.NewServer(
+		greet,
+		func(context.Context, *http.Request) (testRequest, error) { panic("boom") },
+		kit.EncodeJSONResponse[testResponse],
+		kit.ServerTimeout[testRequest, testResponse](time.Minute),
+		kit.ServerRecover[testRequest, testResponse](kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// the panic of the decoder goroutine reaches the recovery of the server
+	if want, have := http.StatusInternalServerError, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	var panicErr *kit.PanicError
+	if !errors.As(handled, &panicErr) {
+		t.Errorf("want *kit.PanicError, have %T", handled)
+	}
+}
@@ -0,0 +1,291 @@
+package serverutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCheckTimeout is the time given to a checker to report.
+	DefaultCheckTimeout = 5 * time.Second
+
+	// DefaultHealthCacheTTL is the time the result of a checker is reused.
+	DefaultHealthCacheTTL = time.Second
+)
+
+// Status values reported by the health endpoints.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// Checker reports the health of a component, such as a database, a nil
+// error means healthy. It must honor the deadline of the context.
+type Checker func(ctx context.Context) error
+
+// CheckOption configures a checker registered in Health.
+type CheckOption func(*check)
+
+// CheckTimeout sets the time given to the checker to report, a checker that
+// doesn't report in time is failing. By default, DefaultCheckTimeout is used.
+func CheckTimeout(d time.Duration) CheckOption {
+	return func(c *check) { c.timeout = d }
+}
+
+// CheckNonCritical makes the failures of the checker degrade the service
+// without failing it, the endpoints keep answering 200. By default, checkers
+// are critical.
+func CheckNonCritical() CheckOption {
+	return func(c *check) { c.critical = false }
+}
+
+// CheckLiveness makes the checker part of /livez. Only the components whose
+// failure requires a restart of the process should be liveness checkers. By
+// default, checkers are only part of /healthz and /readyz.
+func CheckLiveness() CheckOption {
+	return func(c *check) { c.liveness = true }
+}
+
+type check struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+	liveness bool
+
+	mu      sync.Mutex // guards the cached result
+	result  CheckResult
+	checked time.Time
+}
+
+// CheckResult is the outcome of a checker, as reported by the endpoints.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+}
+
+// HealthReport is the body of the health endpoints.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthOption configures Health.
+type HealthOption func(*Health)
+
+// HealthCacheTTL sets the time the result of a checker is reused, so probes
+// don't overload the components. By default, DefaultHealthCacheTTL is used.
+func HealthCacheTTL(d time.Duration) HealthOption {
+	return func(h *Health) { h.ttl = d }
+}
+
+// Health runs the registered checkers and serves the results:
+//
+//	/healthz  every checker
+//	/readyz   every checker, failing while the server drains, see WithHealth
+//	/livez    the liveness checkers only
+//
+// The endpoints answer 200 when every critical checker passes, the status is
+// "degraded" when a non critical checker fails, otherwise they answer 503.
+// The body is a HealthReport.
+type Health struct {
+	mu       sync.RWMutex // guards checks
+	checks   []*check
+	ttl      time.Duration
+	draining int32
+}
+
+// NewHealth returns a Health without checkers.
+func NewHealth(opts ...HealthOption) *Health {
+	h := &Health{ttl: DefaultHealthCacheTTL}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Register adds a checker with the given name, replacing the one with the
+// same name.
+func (h *Health) Register(name string, checker Checker, opts ...CheckOption) {
+	c := &check{
+		name:     name,
+		checker:  checker,
+		timeout:  DefaultCheckTimeout,
+		critical: true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, registered := range h.checks {
+		if registered.name == name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+}
+
+// Drain marks the service as not ready, /readyz fails from now on. It's
+// called by Run when the shutdown starts, see WithHealth.
+func (h *Health) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// Draining reports whether Drain was called.
+func (h *Health) Draining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// Handler returns a handler serving /healthz, /readyz and /livez.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h.Healthz())
+	mux.Handle("/readyz", h.Readyz())
+	mux.Handle("/livez", h.Livez())
+	return mux
+}
+
+// Healthz returns a handler reporting every checker.
+func (h *Health) Healthz() http.Handler {
+	return h.handler(false, false)
+}
+
+// Readyz returns a handler reporting every checker, which fails while the
+// service drains.
+func (h *Health) Readyz() http.Handler {
+	return h.handler(false, true)
+}
+
+// Livez returns a handler reporting the liveness checkers.
+func (h *Health) Livez() http.Handler {
+	return h.handler(true, false)
+}
+
+func (h *Health) handler(liveness, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context(), liveness)
+		if readiness && h.Draining() {
+			report.Status = StatusDraining
+		}
+
+		code := http.StatusOK
+		if report.Status == StatusFail || report.Status == StatusDraining {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Check runs the checkers concurrently, reusing the cached results, and
+// reports them. When liveness is set, only the liveness checkers are run.
+// The checkers are bounded by their own timeout, not by ctx, whose end only
+// stops the wait: the checks still running are reported as failing, without
+// caching that result.
+func (h *Health) Check(ctx context.Context, liveness bool) HealthReport {
+	h.mu.RLock()
+	checks := make([]*check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !liveness || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	// the checks run detached from ctx, so a probe going away doesn't cache
+	// a failure, it only stops waiting for the results
+	type indexedResult struct {
+		i      int
+		result CheckResult
+	}
+	done := make(chan indexedResult, len(checks))
+	for i, c := range checks {
+		go func(i int, c *check) { done <- indexedResult{i, c.run(h.ttl)} }(i, c)
+	}
+
+	results := make([]CheckResult, len(checks))
+	received := make([]bool, len(checks))
+wait:
+	for range checks {
+		select {
+		case r := <-done:
+			results[r.i], received[r.i] = r.result, true
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	for i, c := range checks {
+		if !received[i] {
+			results[i] = CheckResult{Status: StatusFail, Critical: c.critical, Error: ctx.Err().Error()}
+		}
+	}
+
+	report := HealthReport{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+	for i, c := range checks {
+		result := results[i]
+		report.Checks[c.name] = result
+		switch {
+		case result.Status == StatusOK:
+		case c.critical:
+			report.Status = StatusFail
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run returns the cached result while it's fresh, otherwise it runs the
+// checker, bounded by the timeout of the check only. Concurrent probes wait
+// for the same run.
+func (c *check) run(ttl time.Duration) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && time.Since(c.checked) < ttl {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- c.checker(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.result = CheckResult{
+		Status:   StatusOK,
+		Critical: c.critical,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		c.result.Status = StatusFail
+		c.result.Error = err.Error()
+	}
+	c.checked = time.Now()
+	return c.result
+}
@@ -0,0 +1,122 @@
+package serverutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit/v2/serverutil"
+)
+
+func TestHealth(t *testing.T) {
+	var (
+		dbErr    error
+		cacheErr = errors.New("cache down")
+		calls    int
+	)
+
+	h := serverutil.NewHealth(serverutil.HealthCacheTTL(time.Hour))
+	h.Register("db", func(context.Context) error { calls++; return dbErr })
+	h.Register("cache", func(context.Context) error { return cacheErr }, serverutil.CheckNonCritical())
+	h.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, serverutil.CheckNonCritical(), serverutil.CheckTimeout(10*time.Millisecond))
+	h.Register("loop", func(context.Context) error { return nil }, serverutil.CheckLiveness())
+
+	tc := [...]struct {
+		path   string
+		code   int
+		status string
+		checks int
+	}{
+		{path: "/healthz", code: http.StatusOK, status: serverutil.StatusDegraded, checks: 4},
+		{path: "/readyz", code: http.StatusOK, status: serverutil.StatusDegraded, checks: 4},
+		{path: "/livez", code: http.StatusOK, status: serverutil.StatusOK, checks: 1},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if want, have := tt.code, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+
+			var report serverutil.HealthReport
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if want, have := tt.status, report.Status; want != have {
+				t.Errorf("want %s, have %s", want, have)
+			}
+			if want, have := tt.checks, len(report.Checks); want != have {
+				t.Errorf("want %d checks, have %d", want, have)
+			}
+		})
+	}
+
+	if want, have := 1, calls; want != have {
+		t.Errorf("want %d calls to the cached checker, have %d", want, have)
+	}
+
+	h.Drain()
+	rec := httptest.NewRecorder()
+	h.Readyz().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if want, have := http.StatusServiceUnavailable, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	rec = httptest.NewRecorder()
+	h.Livez().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestHealthCriticalFailure(t *testing.T) {
+	h := serverutil.NewHealth()
+	h.Register("db", func(context.Context) error { return errors.New("db down") })
+
+	rec := httptest.NewRecorder()
+	h.Healthz().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if want, have := http.StatusServiceUnavailable, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	var report serverutil.HealthReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "db down", report.Checks["db"].Error; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestHealthAbortedProbe(t *testing.T) {
+	h := serverutil.NewHealth(serverutil.HealthCacheTTL(time.Hour))
+	h.Register("db", func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if want, have := serverutil.StatusFail, h.Check(ctx, false).Status; want != have {
+		t.Errorf("aborted probe: want %s, have %s", want, have)
+	}
+
+	// the aborted probe didn't cache its failure, the check completed on its own
+	if want, have := serverutil.StatusOK, h.Check(context.Background(), false).Status; want != have {
+		t.Errorf("next probe: want %s, have %s", want, have)
+	}
+}
@@ -0,0 +1,220 @@
+package serverutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ListenUnix listens on a unix domain socket at path, with the given file
+// permissions, so the access can be restricted to the processes of a user or
+// a group. The socket is created in a private directory and moved to path
+// once its permissions are set, so it's never reachable with broader ones. A
+// stale socket left by a previous process is replaced, while any other kind
+// of file at path is an error. The socket is removed when the listener is
+// closed.
+//
+//	ln, err := serverutil.ListenUnix("/run/app/http.sock", 0o660)
+//	...
+//	err = serverutil.Run(ctx, srv, serverutil.WithListener(ln))
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".listen-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	ln.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ln, path: path}, nil
+}
+
+// unixListener reports the final path of a socket created by ListenUnix,
+// and removes it when closed.
+type unixListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+// SystemdListeners returns the listeners passed by systemd-style socket
+// activation, in the order of their file descriptors. It returns no
+// listeners when the process was not activated, that is, when LISTEN_PID
+// doesn't match the process. The LISTEN_* environment variables are unset,
+// so they are not inherited by child processes, which means this function
+// can be called only once.
+func SystemdListeners() ([]net.Listener, error) {
+	files := activationFiles(listenFdsStart)
+	listeners := make([]net.Listener, 0, len(files))
+	for _, f := range files {
+		ln, err := fileListener(f.file)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// SystemdListenersWithNames works as SystemdListeners, but the listeners are
+// grouped by the names given in LISTEN_FDNAMES, as set with the
+// FileDescriptorName option of the socket units.
+func SystemdListenersWithNames() (map[string][]net.Listener, error) {
+	files := activationFiles(listenFdsStart)
+	listeners := make(map[string][]net.Listener, len(files))
+	for _, f := range files {
+		ln, err := fileListener(f.file)
+		if err != nil {
+			for _, lns := range listeners {
+				closeListeners(lns)
+			}
+			return nil, err
+		}
+		listeners[f.name] = append(listeners[f.name], ln)
+	}
+	return listeners, nil
+}
+
+type activationFile struct {
+	name string
+	file *os.File
+}
+
+// activationFiles returns the files passed by socket activation, starting at
+// the given file descriptor, and unsets the environment.
+func activationFiles(start int) []activationFile {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := make([]activationFile, 0, n)
+	for i := 0; i < n; i++ {
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+		fd := start + i
+		files = append(files, activationFile{
+			name: name,
+			file: os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)),
+		})
+	}
+	return files
+}
+
+// fileListener returns a listener over a duplicate of the file, which is
+// closed.
+func fileListener(f *os.File) (net.Listener, error) {
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return ln, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
+// LimitListener returns a listener that accepts at most n simultaneous
+// connections, Accept blocks until one of the connections is closed.
+func LimitListener(l net.Listener, n int) net.Listener {
+	return &limitListener{
+		Listener: l,
+		sem:      make(chan struct{}, n),
+		done:     make(chan struct{}),
+	}
+}
+
+var errListenerClosed = errors.New("listener closed")
+
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: errListenerClosed}
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: c, release: func() { <-l.sem }}, nil
+}
+
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
@@ -0,0 +1,145 @@
+package serverutil
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.sock")
+
+	// a stale socket left behind by a previous process is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := ListenUnix(path, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := os.FileMode(0o660), info.Mode().Perm(); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := path, ln.Addr().String(); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ln.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("want the socket removed, have %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if want, have := 0, len(entries); want != have {
+		t.Errorf("want %d leftover files, have %d", want, have)
+	}
+
+	file := filepath.Join(t.TempDir(), "regular")
+	os.WriteFile(file, nil, 0o600)
+	if _, err := ListenUnix(file, 0o660); err == nil {
+		t.Error("want an error for a regular file, have none")
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "web")
+
+	files := activationFiles(int(f.Fd()))
+	if want, have := 1, len(files); want != have {
+		t.Fatalf("want %d files, have %d", want, have)
+	}
+	if want, have := "web", files[0].name; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("want LISTEN_FDS unset")
+	}
+
+	ln, err := fileListener(files[0].file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if want, have := tcp.Addr().String(), ln.Addr().String(); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	// another process was activated
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	if files := activationFiles(listenFdsStart); len(files) != 0 {
+		t.Errorf("want no files, have %d", len(files))
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := LimitListener(tcp, 1)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", tcp.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	first := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("want the second connection to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("want the second connection accepted")
+	}
+}
@@ -0,0 +1,224 @@
+package serverutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time given by Run to the in-flight requests
+// and the shutdown hooks to finish.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ShutdownHook releases a resource once the server stopped serving, such as
+// a database connection pool. The context carries the shutdown deadline.
+type ShutdownHook func(ctx context.Context) error
+
+// RunOption configures Run.
+type RunOption func(*runner)
+
+type runner struct {
+	shutdownTimeout time.Duration
+	hooks           []ShutdownHook
+	listener        net.Listener
+	signals         []os.Signal
+	health          *Health
+	drainDelay      time.Duration
+}
+
+// WithShutdownTimeout sets the time given to the in-flight requests and the
+// shutdown hooks to finish. By default, DefaultShutdownTimeout is used.
+func WithShutdownTimeout(d time.Duration) RunOption {
+	return func(r *runner) { r.shutdownTimeout = d }
+}
+
+// WithShutdownHook registers hooks which are run once the server is drained,
+// in the reverse order of their registration, so resources are released in
+// the opposite order they were acquired.
+func WithShutdownHook(hooks ...ShutdownHook) RunOption {
+	return func(r *runner) { r.hooks = append(r.hooks, hooks...) }
+}
+
+// WithListener serves on the given listener instead of listening on the
+// address of the server.
+func WithListener(l net.Listener) RunOption {
+	return func(r *runner) { r.listener = l }
+}
+
+// WithSignals sets the signals which start the shutdown. By default, SIGINT
+// and SIGTERM are used.
+func WithSignals(signals ...os.Signal) RunOption {
+	return func(r *runner) { r.signals = signals }
+}
+
+// WithHealth marks the service as not ready, through Health.Drain, as soon as
+// the shutdown starts.
+func WithHealth(h *Health) RunOption {
+	return func(r *runner) { r.health = h }
+}
+
+// WithDrainDelay sets the time the server keeps serving once it's marked as
+// not ready, so load balancers stop routing requests to it before it stops
+// accepting connections. It's only useful along with WithHealth. By default,
+// there is no delay.
+func WithDrainDelay(d time.Duration) RunOption {
+	return func(r *runner) { r.drainDelay = d }
+}
+
+// Run serves the requests until the context is done or one of the signals is
+// received, then it shuts the server down gracefully: the in-flight requests
+// are drained within the shutdown timeout, and the shutdown hooks are run.
+// Another signal received while shutting down terminates the process. TLS is
+// served when the server has a certificate, see WithCertReloader.
+//
+// A clean shutdown returns nil, otherwise the errors found while serving,
+// draining and running the hooks are returned together as Errors.
+//
+//	srv := serverutil.NewServer(":8080", handler)
+//	err := serverutil.Run(ctx, srv,
+//		serverutil.WithShutdownHook(func(ctx context.Context) error {
+//			return db.Close()
+//		}),
+//	)
+func Run(ctx context.Context, srv *http.Server, opts ...RunOption) error {
+	r := runner{
+		shutdownTimeout: DefaultShutdownTimeout,
+		signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	var errs Errors
+
+	ln := r.listener
+	if ln == nil {
+		addr := srv.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			errs = append(errs, err)
+			errs = append(errs, r.runHooks(context.Background())...)
+			return errs.err()
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg := srv.TLSConfig; cfg != nil && (len(cfg.Certificates) > 0 || cfg.GetCertificate != nil) {
+			serveErr <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- srv.Serve(ln)
+	}()
+
+	ctx, stop := signal.NotifyContext(ctx, r.signals...)
+	defer stop()
+
+	served := false
+	select {
+	case err := <-serveErr:
+		served = true
+		if err != http.ErrServerClosed {
+			errs = append(errs, err)
+		}
+	case <-ctx.Done():
+	}
+
+	// restore the default behavior, so a second signal terminates the process
+	stop()
+
+	if r.health != nil {
+		r.health.Drain()
+	}
+	if !served && r.drainDelay > 0 {
+		time.Sleep(r.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+		srv.Close()
+	}
+	if !served {
+		if err := <-serveErr; err != http.ErrServerClosed {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, r.runHooks(shutdownCtx)...)
+	return errs.err()
+}
+
+// runHooks runs the shutdown hooks in reverse order.
+func (r *runner) runHooks(ctx context.Context) Errors {
+	var errs Errors
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		if err := r.hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Errors is returned by Run when more than one step of the shutdown fails.
+type Errors []error
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors, it's used by errors.Is and errors.As from Go
+// 1.20.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target, so errors.Is inspects
+// all of them on every Go version.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, so errors.As
+// inspects all of them on every Go version.
+func (e Errors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns nil when there are no errors, or the error itself when there
+// is only one.
+func (e Errors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
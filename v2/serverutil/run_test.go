@@ -0,0 +1,157 @@
+package serverutil_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mishudark/kit/v2/serverutil"
+)
+
+func TestRun(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		hooks   []string
+		errHook = errors.New("hook failed")
+		health  = serverutil.NewHealth()
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- serverutil.Run(ctx, serverutil.NewServer("", handler),
+			serverutil.WithListener(ln),
+			serverutil.WithHealth(health),
+			serverutil.WithShutdownHook(
+				func(context.Context) error { hooks = append(hooks, "first"); return nil },
+				func(context.Context) error { hooks = append(hooks, "second"); return errHook },
+			),
+		)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if want, have := "done", <-body; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if err := <-runErr; !errors.Is(err, errHook) {
+		t.Errorf("want %v, have %v", errHook, err)
+	}
+	if want, have := "second,first", strings.Join(hooks, ","); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if !health.Draining() {
+		t.Error("want the service marked as draining")
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- serverutil.Run(ctx, serverutil.NewServer("", handler),
+			serverutil.WithListener(ln),
+			serverutil.WithShutdownTimeout(10*time.Millisecond),
+		)
+	}()
+
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+
+	if err := <-runErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+}
+
+type hookError struct{ name string }
+
+func (e *hookError) Error() string { return e.name + " failed" }
+
+func TestRunShutdownErrors(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- serverutil.Run(ctx, serverutil.NewServer("", handler),
+			serverutil.WithListener(ln),
+			serverutil.WithShutdownTimeout(10*time.Millisecond),
+			serverutil.WithShutdownHook(func(context.Context) error { return &hookError{name: "db"} }),
+		)
+	}()
+
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+
+	err = <-runErr
+	var errs serverutil.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("want 2 errors, have %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v in %v", context.DeadlineExceeded, err)
+	}
+	var hookErr *hookError
+	if !errors.As(err, &hookErr) {
+		t.Fatalf("want *hookError in %v", err)
+	}
+	if want, have := "db", hookErr.name; want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
@@ -0,0 +1,153 @@
+package serverutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCertCheckInterval is the minimum time between two checks of the
+// certificate files made by CertReloader.
+const DefaultCertCheckInterval = 10 * time.Second
+
+// CertReloader serves a certificate loaded from files, which is reloaded when
+// the files change on disk, so renewed certificates are picked up without a
+// restart. The files are checked at most once per DefaultCertCheckInterval,
+// during the handshakes. When the new files can not be loaded, the previous
+// certificate is kept.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex // guards the fields below
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+	interval time.Duration
+}
+
+// NewCertReloader loads the certificate and the key from the PEM encoded
+// files, an error is returned if they can not be loaded.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: DefaultCertCheckInterval,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate, it has the signature of
+// tls.Config.GetCertificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checked) >= c.interval {
+		// keep serving the previous certificate on error
+		c.reloadLocked()
+	}
+	return c.cert, nil
+}
+
+func (c *CertReloader) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reloadLocked()
+}
+
+// reloadLocked loads the files again if they were modified.
+func (c *CertReloader) reloadLocked() error {
+	c.checked = time.Now()
+
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.modTime = &cert, modTime
+	return nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// LoadCertPool returns a pool with the certificates of the PEM encoded files,
+// such as a CA bundle.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration of the server, creating it when
+// needed with TLS 1.2 as the minimum version.
+func tlsConfig(srv *http.Server) *tls.Config {
+	if srv.TLSConfig == nil {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return srv.TLSConfig
+}
+
+// WithCertReloader serves the certificate of the CertReloader, see
+// NewCertReloader.
+func WithCertReloader(c *CertReloader) Option {
+	return func(srv *http.Server) { tlsConfig(srv).GetCertificate = c.GetCertificate }
+}
+
+// WithMinTLSVersion sets the minimum TLS version accepted, such as
+// tls.VersionTLS13. By default, TLS 1.2 is the minimum version.
+func WithMinTLSVersion(version uint16) Option {
+	return func(srv *http.Server) { tlsConfig(srv).MinVersion = version }
+}
+
+// WithCipherSuites sets the cipher suites enabled for TLS 1.0 to 1.2, the
+// TLS 1.3 suites are not configurable.
+func WithCipherSuites(suites ...uint16) Option {
+	return func(srv *http.Server) { tlsConfig(srv).CipherSuites = suites }
+}
+
+// WithClientCAs requires the clients to present a certificate signed by one
+// of the authorities of the pool, see LoadCertPool. The verified certificate
+// is exposed to the handlers by kit.PopulateRequestContext, under
+// kit.ContextKeyRequestPeerCertificate.
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(srv *http.Server) {
+		cfg := tlsConfig(srv)
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+}
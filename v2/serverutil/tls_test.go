@@ -0,0 +1,194 @@
+package serverutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+// testCert issues a certificate for the given name, signed by the parent or
+// self signed when parent is nil.
+func testCert(t *testing.T, name string, parent *tls.Certificate, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	signer, signerKey := tmpl, any(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func writeCert(t *testing.T, cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := testCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	server := testCert(t, "127.0.0.1", &ca, x509.ExtKeyUsageServerAuth)
+	client := testCert(t, "billing", &ca, x509.ExtKeyUsageClientAuth)
+
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	writeCert(t, server, certFile, keyFile)
+	writeCert(t, ca, caFile, filepath.Join(dir, "ca.key"))
+
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := LoadCertPool(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := kit.PopulateRequestContext(r.Context(), r)
+		peer, _ := ctx.Value(kit.ContextKeyRequestPeerCertificate).(*x509.Certificate)
+		if peer != nil {
+			io.WriteString(w, peer.Subject.CommonName)
+		}
+	})
+	srv := NewServer("", handler,
+		WithCertReloader(certs),
+		WithMinTLSVersion(tls.VersionTLS12),
+		WithCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256),
+		WithClientCAs(pool),
+	)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, srv, WithListener(ln))
+
+	url := "https://" + ln.Addr().String()
+	tc := [...]struct {
+		name  string
+		certs []tls.Certificate
+		want  string
+		fails bool
+	}{
+		{name: "client certificate", certs: []tls.Certificate{client}, want: "billing"},
+		{name: "no client certificate", fails: true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      pool,
+				Certificates: tt.certs,
+			}}}
+
+			resp, err := c.Get(url)
+			if tt.fails {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("want a handshake error, have none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			b, _ := io.ReadAll(resp.Body)
+			if want, have := tt.want, string(b); want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := testCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, testCert(t, "old", &ca, x509.ExtKeyUsageServerAuth), certFile, keyFile)
+
+	c, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.interval = 0
+
+	name := func() string {
+		cert, err := c.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+
+	if want, have := "old", name(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	writeCert(t, testCert(t, "new", &ca, x509.ExtKeyUsageServerAuth), certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if want, have := "new", name(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// broken files keep the previous certificate
+	os.WriteFile(keyFile, []byte("broken"), 0o600)
+	os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute))
+	if want, have := "new", name(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
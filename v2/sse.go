@@ -0,0 +1,223 @@
+package kit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mishudark/errors"
+)
+
+// DefaultHeartbeat is the interval used by EncodeEventStream to send a
+// comment when no event was written, it keeps the connection open through
+// proxies.
+const DefaultHeartbeat = 15 * time.Second
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is sent back by the client in the Last-Event-ID header when it
+	// reconnects.
+	ID string
+
+	// Name is the event type, clients listen for "message" when empty.
+	Name string
+
+	// Data is the payload of the event, strings and []byte are written as
+	// is, other values are JSON encoded.
+	Data any
+
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// NewEventStreamServer constructs a Server which streams the events returned
+// by the handler as text/event-stream, see EncodeEventStream. The
+// Last-Event-ID header sent by reconnecting clients is available in the
+// context under ContextKeyRequestLastEventID, so the handler can resume the
+// stream. Before, after, finalizer and error hooks run as in any Server.
+//
+// The sequence is consumed in its own goroutine, which is released when the
+// sequence returns. If the client disconnects while the sequence is blocked
+// waiting for the next event, it must watch the context given to the handler
+// to return, see FromChanContext, otherwise the goroutine leaks.
+func NewEventStreamServer[I any](
+	h HandlerFunc[I, Seq[Event]],
+	dec DecodeRequestFunc[I],
+	options ...ServerOption[I, Seq[Event]],
+) *Server[I, Seq[Event]] {
+	options = append([]ServerOption[I, Seq[Event]]{
+		ServerBefore[I, Seq[Event]](populateLastEventID),
+	}, options...)
+
+	return NewServer(h, dec, EncodeEventStream, options...)
+}
+
+func populateLastEventID(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, ContextKeyRequestLastEventID, r.Header.Get("Last-Event-ID"))
+}
+
+// EncodeEventStream is a EncodeResponseFunc that writes the events as
+// text/event-stream, sending a heartbeat every DefaultHeartbeat. See
+// NewEventStreamEncoder.
+func EncodeEventStream(ctx context.Context, w http.ResponseWriter, events Seq[Event]) error {
+	return encodeEventStream(ctx, w, events, DefaultHeartbeat)
+}
+
+// NewEventStreamEncoder returns a EncodeResponseFunc that writes the events
+// as text/event-stream, flushing each one. A comment is sent when no event
+// was written during the heartbeat interval, a zero interval disables it.
+// The stream ends when the sequence is exhausted or when the client
+// disconnects. An error yielded by the sequence is sent as an event named
+// "error" and returned as a *StreamError, and so is a panic raised by the
+// sequence, as a *PanicError.
+func NewEventStreamEncoder(heartbeat time.Duration) EncodeReponseFunc[Seq[Event]] {
+	return func(ctx context.Context, w http.ResponseWriter, events Seq[Event]) error {
+		return encodeEventStream(ctx, w, events, heartbeat)
+	}
+}
+
+type eventOrError struct {
+	event Event
+	err   error
+}
+
+func encodeEventStream(ctx context.Context, w http.ResponseWriter, events Seq[Event], heartbeat time.Duration) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported by the response writer")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// the sequence is consumed in its own goroutine, so heartbeats and
+	// disconnections are noticed while waiting for the next event
+	items := make(chan eventOrError)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(items)
+		defer func() {
+			// the Server can't recover a panic raised in this goroutine, it's
+			// reported in-band as any other error of the sequence
+			if p := recover(); p != nil {
+				select {
+				case items <- eventOrError{err: DefaultRecoveryHandler(ctx, p)}:
+				case <-stop:
+				}
+			}
+		}()
+		events(func(event Event, err error) bool {
+			select {
+			case items <- eventOrError{event, err}:
+				return err == nil
+			case <-stop:
+				return false
+			}
+		})
+	}()
+
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var b []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+			b = []byte(": heartbeat\n\n")
+		case item, ok := <-items:
+			if !ok {
+				return nil
+			}
+			if item.err != nil {
+				writeEvent(w, Event{Name: "error", Data: item.err.Error()})
+				flusher.Flush()
+				return &StreamError{Err: item.err}
+			}
+
+			var err error
+			if b, err = marshalEvent(item.event); err != nil {
+				writeEvent(w, Event{Name: "error", Data: err.Error()})
+				flusher.Flush()
+				return &StreamError{Err: err}
+			}
+			addResponseItems(ctx, 1)
+			if ticker != nil {
+				// the heartbeat is only needed when the stream is idle
+				ticker.Reset(heartbeat)
+			}
+		}
+
+		if _, err := w.Write(b); err != nil {
+			return &StreamError{Err: err}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	b, err := marshalEvent(event)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// marshalEvent serializes the event in the text/event-stream format.
+func marshalEvent(event Event) ([]byte, error) {
+	var data []byte
+	switch d := event.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		var err error
+		if data, err = json.Marshal(d); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	if event.ID != "" {
+		b.WriteString("id: " + stripNewlines(event.ID) + "\n")
+	}
+	if event.Name != "" {
+		b.WriteString("event: " + stripNewlines(event.Name) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(bytes.TrimSuffix(line, []byte("\r")))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes(), nil
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
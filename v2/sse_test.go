@@ -0,0 +1,216 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+func TestEventStreamServer(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	var (
+		handled   error
+		finalCode int
+	)
+	handler := kit.NewEventStreamServer(
+		func(ctx context.Context, _ testRequest) (kit.Seq[kit.Event], error) {
+			lastID, _ := ctx.Value(kit.ContextKeyRequestLastEventID).(string)
+			return func(yield func(kit.Event, error) bool) {
+				if !yield(kit.Event{ID: "2", Data: "resumed after " + lastID}, nil) {
+					return
+				}
+				if !yield(kit.Event{ID: "3", Name: "progress", Data: map[string]int{"done": 50}}, nil) {
+					return
+				}
+				yield(kit.Event{}, errBoom)
+			}, nil
+		},
+		kit.DecodeRequest[testRequest],
+		kit.ServerErrorHandler[testRequest, kit.Seq[kit.Event]](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		kit.ServerFinalizer[testRequest, kit.Seq[kit.Event]](func(_ context.Context, code int, _ *http.Request) { finalCode = code }),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if want, have := "text/event-stream", rec.Header().Get("Content-Type"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if !rec.Flushed {
+		t.Error("want a flushed response")
+	}
+
+	want := "id: 2\ndata: resumed after 1\n\n" +
+		"id: 3\nevent: progress\ndata: {\"done\":50}\n\n" +
+		"event: error\ndata: boom\n\n"
+	if have := rec.Body.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if !errors.Is(handled, errBoom) {
+		t.Errorf("want %v, have %v", errBoom, handled)
+	}
+	if want, have := http.StatusOK, finalCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	events := make(chan kit.Event)
+	defer close(events)
+
+	handler := kit.NewServer(
+		func(ctx context.Context, _ testRequest) (kit.Seq[kit.Event], error) {
+			return kit.FromChan(events), nil
+		},
+		kit.DecodeRequest[testRequest],
+		kit.NewEventStreamEncoder(10*time.Millisecond),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 64)
+	n, err := io.ReadAtLeast(resp.Body, buf, len(": heartbeat\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := string(buf[:n]); !strings.HasPrefix(have, ": heartbeat\n\n") {
+		t.Errorf("want a heartbeat, have %q", have)
+	}
+
+	go func() { events <- kit.Event{Data: "hi"} }()
+	for {
+		n, err = resp.Body.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := string(buf[:n]); strings.Contains(have, "data: hi\n\n") {
+			break
+		}
+	}
+}
+
+func TestEventStreamHeartbeatIdleOnly(t *testing.T) {
+	handler := kit.NewServer(
+		func(ctx context.Context, _ testRequest) (kit.Seq[kit.Event], error) {
+			return func(yield func(kit.Event, error) bool) {
+				for i := 0; i < 10; i++ {
+					time.Sleep(10 * time.Millisecond)
+					if !yield(kit.Event{Data: "tick"}, nil) {
+						return
+					}
+				}
+			}, nil
+		},
+		kit.DecodeRequest[testRequest],
+		kit.NewEventStreamEncoder(30*time.Millisecond),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := string(body); strings.Contains(have, ": heartbeat") {
+		t.Errorf("want no heartbeat in a busy stream, have %q", have)
+	}
+}
+
+func TestFromChanContext(t *testing.T) {
+	released := make(chan struct{})
+	handler := kit.NewEventStreamServer(
+		func(ctx context.Context, _ testRequest) (kit.Seq[kit.Event], error) {
+			events := kit.FromChanContext(ctx, make(chan kit.Event))
+			return func(yield func(kit.Event, error) bool) {
+				defer close(released)
+				events(yield)
+			}, nil
+		},
+		kit.DecodeRequest[testRequest],
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cancel()
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("the sequence was not released after the client went away")
+	}
+}
+
+func TestEventStreamPanic(t *testing.T) {
+	var handled error
+	handler := kit.NewEventStreamServer(
+		func(ctx context.Context, _ testRequest) (kit.Seq[kit.Event], error) {
+			return func(yield func(kit.Event, error) bool) {
+				yield(kit.Event{Data: "first"}, nil)
+				panic("boom")
+			}, nil
+		},
+		kit.DecodeRequest[testRequest],
+		kit.ServerRecover[testRequest, kit.Seq[kit.Event]](kit.DefaultRecoveryHandler),
+		kit.ServerErrorHandler[testRequest, kit.Seq[kit.Event]](kit.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want, have := "event: error\ndata: panic: boom\n\n", rec.Body.String(); !strings.Contains(have, want) {
+		t.Errorf("want %q in %q", want, have)
+	}
+	var (
+		streamErr *kit.StreamError
+		panicErr  *kit.PanicError
+	)
+	if !errors.As(handled, &streamErr) || !errors.As(handled, &panicErr) {
+		t.Fatalf("want a *kit.StreamError wrapping a *kit.PanicError, have %v", handled)
+	}
+	if want, have := "boom", panicErr.Value; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
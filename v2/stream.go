@@ -0,0 +1,58 @@
+package kit
+
+import "context"
+
+// Seq is an iterator over a sequence of values, which are pushed to yield
+// until it returns false. A non nil error ends the sequence. It has the shape
+// of iter.Seq2, so range-over-func loops are able to consume it.
+type Seq[T any] func(yield func(T, error) bool)
+
+// FromChan returns a Seq over the values received from ch until it is
+// closed. The consumer may stop early, for instance when the client goes
+// away, so the producer should watch the request context to stop sending.
+// While ch is neither closed nor fed, the sequence blocks, use
+// FromChanContext to also stop when the request is done.
+func FromChan[T any](ch <-chan T) Seq[T] {
+	return func(yield func(T, error) bool) {
+		for v := range ch {
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromChanContext works as FromChan, but it also ends when ctx is done, so
+// the sequence returns once the client goes away, even if ch is never closed.
+func FromChanContext[T any](ctx context.Context, ch <-chan T) Seq[T] {
+	return func(yield func(T, error) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-ch:
+				if !ok || !yield(v, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// StreamError is returned by the streaming encoders when an error happens
+// once the response was committed, that is, after the headers were sent. The
+// error was already reported in-band to the client, so the Server passes it
+// to the ErrorHandler but doesn't encode it with the ErrorEncoder.
+type StreamError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *StreamError) Error() string {
+	return "stream: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,154 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeoutHeader is the request header read by ServerTimeout to
+// shorten the deadline of a request.
+const DefaultTimeoutHeader = "X-Request-Timeout"
+
+// TimeoutError is returned when the deadline set with ServerTimeout passes
+// before the response is produced. It's passed to the ErrorHandler as is, so
+// timeouts can be told apart from the other errors.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+var _ StatusCoder = (*TimeoutError)(nil)
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("request timed out after %s", e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// StatusCode returns 504 Gateway Timeout.
+func (e *TimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// ServerTimeout sets the time given to the decoder and the handler to
+// produce the response, through the deadline of their context. Requests may
+// ask for a shorter deadline with the DefaultTimeoutHeader, see
+// ServerTimeoutHeader. The decoder and the handler run in their own
+// goroutine, once the deadline passes, a *TimeoutError is written with the
+// ErrorEncoder without waiting for them, and their late result is discarded.
+// They should still return when their context is done, to release the
+// goroutine. The encoder isn't bounded by the deadline, so streaming
+// responses are not cut. By default, requests have no deadline.
+func ServerTimeout[I, O any](d time.Duration) ServerOption[I, O] {
+	return func(s *Server[I, O]) {
+		s.timeout = d
+		if s.timeoutHeader == "" {
+			s.timeoutHeader = DefaultTimeoutHeader
+		}
+	}
+}
+
+// ServerTimeoutHeader sets the request header read by ServerTimeout, an empty
+// name disables the override. The value is either a duration, such as 1.5s,
+// or a number of seconds, and it can only shorten the timeout.
+func ServerTimeoutHeader[I, O any](name string) ServerOption[I, O] {
+	return func(s *Server[I, O]) { s.timeoutHeader = name }
+}
+
+// deadlineError replaces err with a *TimeoutError when the deadline set with
+// the given timeout passed. A nil err is returned as is, so a result received
+// as the deadline passes is still written, and so is the error of a deadline
+// inherited from the parent context, which isn't the one of the server.
+func deadlineError(ctx, parent context.Context, timeout time.Duration, err error) error {
+	if timeout <= 0 || err == nil {
+		return err
+	}
+	if ctx.Err() != context.DeadlineExceeded || parent.Err() != nil {
+		return err
+	}
+	return &TimeoutError{Timeout: timeout}
+}
+
+// callResult is the outcome of a call run by awaitDeadline.
+type callResult[T any] struct {
+	value    T
+	err      error
+	panicked bool
+	panic    any
+}
+
+// awaitDeadline runs call in its own goroutine and waits for its result until
+// the deadline set with the given timeout passes, then it returns a
+// *TimeoutError and the call is abandoned. A panic of call is raised again in
+// the calling goroutine, so it reaches the recovery of the server. When the
+// deadline is inherited from the parent context, the result is still awaited,
+// as it isn't the one of the server. Without timeout, call is run as is.
+func awaitDeadline[T any](ctx, parent context.Context, timeout time.Duration, call func() (T, error)) (T, error) {
+	if timeout <= 0 {
+		return call()
+	}
+
+	done := make(chan callResult[T], 1)
+	go func() {
+		res := callResult[T]{panicked: true}
+		defer func() {
+			if res.panicked {
+				res.panic = recover()
+			}
+			done <- res
+		}()
+		res.value, res.err = call()
+		res.panicked = false
+	}()
+
+	var res callResult[T]
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		if parent.Err() == nil {
+			var zero T
+			return zero, &TimeoutError{Timeout: timeout}
+		}
+		res = <-done
+	}
+	if res.panicked {
+		panic(res.panic)
+	}
+	return res.value, deadlineError(ctx, parent, timeout, res.err)
+}
+
+// requestTimeout returns the timeout of the request, the header can only
+// shorten the given timeout.
+func requestTimeout(r *http.Request, timeout time.Duration, header string) time.Duration {
+	if header == "" {
+		return timeout
+	}
+	if d, ok := parseTimeout(r.Header.Get(header)); ok && d < timeout {
+		return d
+	}
+	return timeout
+}
+
+// parseTimeout parses a duration such as 1.5s, or a number of seconds.
+func parseTimeout(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	return d, d > 0
+}
@@ -0,0 +1,337 @@
+package kit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validateTag is the struct tag read by Validate, it's not named validate so
+// it doesn't clash with the tags of other validation packages.
+const validateTag = "check"
+
+// Validator is implemented by the requests able to check themselves. It's
+// called by DecodeRequest once the request is decoded and the rules declared
+// with the check struct tag are satisfied.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a field that doesn't satisfy a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+var (
+	_ StatusCoder    = ValidationErrors(nil)
+	_ json.Marshaler = ValidationErrors(nil)
+)
+
+// ValidationErrors is the list of fields that failed validation. It's
+// encoded as a 422 by the error encoders of this package, naming each field.
+type ValidationErrors []FieldError
+
+// Error implements the error interface.
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns 422 Unprocessable Entity.
+func (e ValidationErrors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// MarshalJSON serializes the errors as a dict with the keys "error" and
+// "fields".
+func (e ValidationErrors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}{
+		Error:  "validation failed",
+		Fields: []FieldError(e),
+	})
+}
+
+// Validate checks the rules declared with the check struct tag on v, which
+// must be a struct or a pointer to a struct, nested structs are checked as
+// well. Then, if v, or the value it points to, implements Validator, its
+// Validate method is called. Rules are separated by commas:
+//
+//	required  the field must not be the zero value, nil pointers included
+//	min=N     numbers must be >= N, strings, slices and maps must have at least N elements
+//	max=N     numbers must be <= N, strings, slices and maps must have at most N elements
+//	len=N     strings, slices and maps must have exactly N elements
+//	enum=a|b  the field must be one of the listed values
+//	email     the field must be an email address
+//	regex=re  strings must match the regular expression, it must be the last rule
+//
+// The rules apply to zero values too, so a quantity of 0 fails min=1 and an
+// empty string fails enum, email and regex. Optional fields are declared as
+// pointers, the rules other than required are skipped when they are nil:
+//
+//	type OrderRequest struct {
+//		Qty    int     `json:"qty" check:"min=1"`
+//		Coupon *string `json:"coupon" check:"len=8"`
+//	}
+//
+// The field is named after its json tag when available. Failed rules are
+// returned as ValidationErrors, while a malformed or unknown rule is returned
+// as a plain error.
+func Validate(v any) error {
+	var errs ValidationErrors
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		if err := validateStruct(rv, "", &errs); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if validator, ok := findValidator(v); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// findValidator returns v, or the first value it points to, implementing
+// Validator. For instance, decoders call Validate with a **T when the request
+// type is *T. Nil pointers are not followed.
+func findValidator(v any) (Validator, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, false
+		}
+		if validator, ok := rv.Interface().(Validator); ok {
+			return validator, true
+		}
+		if rv.Kind() != reflect.Ptr {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	return nil, false
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fv := rv.Field(i)
+
+		if tag := field.Tag.Get(validateTag); tag != "" && tag != "-" {
+			if err := validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		inner := fv
+		for inner.Kind() == reflect.Ptr && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct {
+			nested := name + "."
+			if field.Anonymous {
+				nested = prefix
+			}
+			if err := validateStruct(inner, nested, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func validateField(fv reflect.Value, name, tag string, errs *ValidationErrors) error {
+	rules := splitRules(tag)
+
+	if fv.IsZero() {
+		for _, rule := range rules {
+			if rule == "required" {
+				*errs = append(*errs, FieldError{Field: name, Rule: "required", Message: "is required"})
+				return nil
+			}
+		}
+	}
+
+	// nil pointers are absent optional fields, only required applies to them
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	for _, rule := range rules {
+		key, arg, _ := strings.Cut(rule, "=")
+		msg, err := checkRule(fv, key, arg)
+		if err != nil {
+			return fmt.Errorf("field %s: invalid %s rule %q: %w", name, validateTag, rule, err)
+		}
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Rule: key, Message: msg})
+		}
+	}
+	return nil
+}
+
+// splitRules splits the tag by commas, everything after regex= is taken as
+// the expression.
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		var rule string
+		rule, tag, _ = strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkRule returns a message describing why fv doesn't satisfy the rule, or
+// an empty string if it does.
+func checkRule(fv reflect.Value, rule, arg string) (string, error) {
+	switch rule {
+	case "required":
+		return "", nil
+	case "min", "max", "len":
+		return checkBound(fv, rule, arg)
+	case "enum":
+		value := fmt.Sprint(fv.Interface())
+		options := strings.Split(arg, "|")
+		for _, option := range options {
+			if value == option {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(options, ", "), nil
+	case "email":
+		if fv.Kind() != reflect.String {
+			return "", fmt.Errorf("unsupported kind %s", fv.Kind())
+		}
+		addr, err := mail.ParseAddress(fv.String())
+		if err != nil || addr.Address != fv.String() {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+	case "regex":
+		if fv.Kind() != reflect.String {
+			return "", fmt.Errorf("unsupported kind %s", fv.Kind())
+		}
+		re, err := compileRegex(arg)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(fv.String()) {
+			return "must match " + arg, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown rule")
+}
+
+func checkBound(fv reflect.Value, rule, arg string) (string, error) {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", err
+		}
+		length := fv.Len()
+		if fv.Kind() == reflect.String {
+			length = len([]rune(fv.String()))
+		}
+		switch {
+		case rule == "min" && length < n:
+			return fmt.Sprintf("must have at least %d elements", n), nil
+		case rule == "max" && length > n:
+			return fmt.Sprintf("must have at most %d elements", n), nil
+		case rule == "len" && length != n:
+			return fmt.Sprintf("must have exactly %d elements", n), nil
+		}
+		return "", nil
+	}
+
+	if rule == "len" {
+		return "", fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "", err
+	}
+
+	var value float64
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = fv.Float()
+	default:
+		return "", fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+
+	switch {
+	case rule == "min" && value < bound:
+		return "must be at least " + arg, nil
+	case rule == "max" && value > bound:
+		return "must be at most " + arg, nil
+	}
+	return "", nil
+}
+
+var regexCache sync.Map // map[string]*regexp.Regexp
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, re)
+	return re, nil
+}
@@ -0,0 +1,170 @@
+package kit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+type address struct {
+	Zip string `json:"zip" check:"required,len=5"`
+}
+
+type signupRequest struct {
+	Name     string   `json:"name" check:"required,min=2,max=10"`
+	Email    *string  `json:"email" check:"email"`
+	Age      int      `json:"age" check:"min=18,max=130"`
+	Plan     string   `json:"plan" check:"enum=free|pro"`
+	Username *string  `json:"username" check:"regex=^[a-z]{1,3}$"`
+	Tags     []string `json:"tags" check:"max=2"`
+	Address  address  `json:"address"`
+}
+
+func ptr(s string) *string { return &s }
+
+func TestValidate(t *testing.T) {
+	tc := [...]struct {
+		name   string
+		req    signupRequest
+		fields []string
+	}{
+		{
+			name: "valid",
+			req: signupRequest{
+				Name:     "kit",
+				Email:    ptr("kit@example.com"),
+				Age:      30,
+				Plan:     "pro",
+				Username: ptr("abc"),
+				Address:  address{Zip: "12345"},
+			},
+		},
+		{
+			name:   "zero values",
+			req:    signupRequest{},
+			fields: []string{"name:required", "age:min", "plan:enum", "address.zip:required"},
+		},
+		{
+			name: "every rule",
+			req: signupRequest{
+				Name:     "k",
+				Email:    ptr("not an email"),
+				Age:      12,
+				Plan:     "enterprise",
+				Username: ptr("abcd"),
+				Tags:     []string{"a", "b", "c"},
+				Address:  address{Zip: "123"},
+			},
+			fields: []string{
+				"name:min",
+				"email:email",
+				"age:min",
+				"plan:enum",
+				"username:regex",
+				"tags:max",
+				"address.zip:len",
+			},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			err := kit.Validate(&tt.req)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("want nil, have %v", err)
+				}
+				return
+			}
+
+			var errs kit.ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("want kit.ValidationErrors, have %v", err)
+			}
+
+			var have []string
+			for _, fe := range errs {
+				have = append(have, fe.Field+":"+fe.Rule)
+			}
+			if !reflect.DeepEqual(tt.fields, have) {
+				t.Errorf("want %v, have %v", tt.fields, have)
+			}
+		})
+	}
+}
+
+type evenRequest struct {
+	N int `json:"n"`
+}
+
+func (r evenRequest) Validate() error {
+	if r.N%2 != 0 {
+		return kit.ValidationErrors{{Field: "n", Rule: "even", Message: "must be even"}}
+	}
+	return nil
+}
+
+func TestDecodeRequestValidates(t *testing.T) {
+	handler := kit.NewServer(
+		func(_ context.Context, req evenRequest) (evenRequest, error) { return req, nil },
+		kit.DecodeRequest[evenRequest],
+		kit.EncodeJSONResponse[evenRequest],
+		kit.ServerErrorEncoder[evenRequest, evenRequest](kit.ProblemErrorEncoder),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"n": 3}`)))
+
+	if want, have := http.StatusUnprocessableEntity, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	var problem struct {
+		Errors []kit.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []kit.FieldError{{Field: "n", Rule: "even", Message: "must be even"}}, problem.Errors; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestValidatePointerRequest(t *testing.T) {
+	tc := [...]struct {
+		name string
+		in   any
+		want bool
+	}{
+		{name: "value", in: &evenRequest{N: 3}, want: true},
+		{name: "pointer to pointer", in: func() any { req := &evenRequest{N: 3}; return &req }(), want: true},
+		{name: "nil pointer", in: new(*evenRequest), want: false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs kit.ValidationErrors
+			if want, have := tt.want, errors.As(kit.Validate(tt.in), &errs); want != have {
+				t.Errorf("want failed validation %v, have %v", want, have)
+			}
+		})
+	}
+}
+
+func TestValidateIgnoresOtherTags(t *testing.T) {
+	// rules meant for other validation packages are not read
+	type adultRequest struct {
+		Age int `json:"age" validate:"gte=18"`
+	}
+
+	if err := kit.Validate(&adultRequest{Age: 12}); err != nil {
+		t.Errorf("want nil, have %v", err)
+	}
+}
@@ -0,0 +1,535 @@
+package kit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mishudark/errors"
+)
+
+// websocketGUID is appended to the Sec-WebSocket-Key to compute the
+// Sec-WebSocket-Accept header, as defined by RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultWebSocketReadLimit is the size limit of the inbound messages used by
+// WebSocket servers.
+const DefaultWebSocketReadLimit = 32 << 20 // 32 MB
+
+// DecodeMessageFunc extracts a user-domain request object from the payload of
+// a WebSocket message.
+type DecodeMessageFunc[I any] func(context.Context, []byte) (req I, err error)
+
+// EncodeMessageFunc encodes the response object into the payload of a
+// WebSocket message. A nil payload means that no reply is sent.
+type EncodeMessageFunc[O any] func(context.Context, O) ([]byte, error)
+
+// MessageErrorEncoder encodes an error into the payload of a WebSocket
+// message, which is sent in place of the reply. A nil payload means that no
+// reply is sent.
+type MessageErrorEncoder func(ctx context.Context, err error) []byte
+
+// DecodeJSONMessage is a DecodeMessageFunc that decodes a JSON payload, then
+// the decoded request is checked with Validate.
+func DecodeJSONMessage[I any](_ context.Context, data []byte) (in I, err error) {
+	if err := json.Unmarshal(data, &in); err != nil {
+		return in, errors.E(err, "can not unmarshal message", errors.Unmarshal)
+	}
+	return in, Validate(&in)
+}
+
+// EncodeJSONMessage is a EncodeMessageFunc that serializes the response as
+// JSON.
+func EncodeJSONMessage[O any](_ context.Context, response O) ([]byte, error) {
+	return json.Marshal(response)
+}
+
+// DefaultMessageErrorEncoder encodes the error as a JSON object with the
+// "error" key. If the error implements json.Marshaler, and the marshaling
+// succeeds, the JSON encoded form of the error will be used instead.
+func DefaultMessageErrorEncoder(_ context.Context, err error) []byte {
+	if marshaler, ok := err.(json.Marshaler); ok {
+		if body, marshalErr := marshaler.MarshalJSON(); marshalErr == nil {
+			return body
+		}
+	}
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return body
+}
+
+// WebSocketServer wraps an business logic service and implements
+// http.Handler, upgrading the requests to the WebSocket protocol. Each
+// message is handled as a request on its own.
+//
+// Upgraded connections are hijacked, so http.Server.Shutdown neither waits
+// for them nor cancels their context. Call Shutdown to close them, for
+// instance as a serverutil.ShutdownHook, registered after the hooks releasing
+// the resources used by the handler, since hooks run in reverse order:
+//
+//	serverutil.Run(ctx, srv,
+//		serverutil.WithShutdownHook(closeDB),
+//		serverutil.WithShutdownHook(ws.Shutdown),
+//	)
+type WebSocketServer[I, O any] struct {
+	h                   HandlerFunc[I, O]
+	dec                 DecodeMessageFunc[I]
+	enc                 EncodeMessageFunc[O]
+	before              []RequestFunc
+	finalizer           []ServerFinalizerFunc
+	errorEncoder        ErrorEncoder
+	messageErrorEncoder MessageErrorEncoder
+	errorHandler        ErrorHandler
+	checkOrigin         func(*http.Request) bool
+	pingInterval        time.Duration
+	readLimit           int64
+	conns               *wsConns
+}
+
+// WebSocketOption sets an optional parameter for WebSocket servers.
+type WebSocketOption[I, O any] func(*WebSocketServer[I, O])
+
+// WebSocketBefore functions are executed on the HTTP request object before
+// the connection is upgraded, the returned context is used for every message.
+func WebSocketBefore[I, O any](before ...RequestFunc) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.before = append(s.before, before...) }
+}
+
+// WebSocketFinalizer is executed once the connection is closed. The code is
+// 101 when the connection was upgraded, the bytes written are available under
+// ContextKeyResponseSize and the number of messages sent under
+// ContextKeyResponseItems. By default, no finalizer is registered.
+func WebSocketFinalizer[I, O any](f ...ServerFinalizerFunc) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.finalizer = append(s.finalizer, f...) }
+}
+
+// WebSocketErrorEncoder is used to encode the errors found before the
+// connection is upgraded, such as a malformed handshake. By default, errors
+// will be written with the DefaultErrorEncoder.
+func WebSocketErrorEncoder[I, O any](ee ErrorEncoder) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.errorEncoder = ee }
+}
+
+// WebSocketMessageErrorEncoder is used to encode the errors returned while
+// processing a message, the connection stays open. By default, errors will be
+// encoded with the DefaultMessageErrorEncoder.
+func WebSocketMessageErrorEncoder[I, O any](ee MessageErrorEncoder) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.messageErrorEncoder = ee }
+}
+
+// WebSocketErrorHandler is used to handle non-terminal errors. By default,
+// non-terminal errors are ignored. This is intended as a diagnostic measure.
+func WebSocketErrorHandler[I, O any](errorHandler ErrorHandler) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.errorHandler = errorHandler }
+}
+
+// WebSocketCheckOrigin sets the function that accepts the Origin of the
+// handshake. By default, only requests without Origin or from the same host
+// are accepted.
+func WebSocketCheckOrigin[I, O any](check func(*http.Request) bool) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.checkOrigin = check }
+}
+
+// WebSocketPingInterval sets the interval between the pings sent to keep the
+// connection alive, the connection is dropped when nothing is received from
+// the peer within two intervals. By default, no pings are sent.
+func WebSocketPingInterval[I, O any](d time.Duration) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) { s.pingInterval = d }
+}
+
+// WebSocketReadLimit limits the size of the inbound messages, the connection
+// is closed with CloseMessageTooBig when a bigger one is received. The limit
+// can't be removed, a non-positive n sets DefaultWebSocketReadLimit, which is
+// also the default.
+func WebSocketReadLimit[I, O any](n int64) WebSocketOption[I, O] {
+	return func(s *WebSocketServer[I, O]) {
+		if n <= 0 {
+			n = DefaultWebSocketReadLimit
+		}
+		s.readLimit = n
+	}
+}
+
+// NewWebSocketServer constructs a new WebSocket server, which implements
+// http.Handler. Messages are handled one at a time, in the order they are
+// received, the reply is sent with the same frame type, text or binary, of
+// the inbound message.
+func NewWebSocketServer[I, O any](
+	h HandlerFunc[I, O],
+	dec DecodeMessageFunc[I],
+	enc EncodeMessageFunc[O],
+	options ...WebSocketOption[I, O],
+) *WebSocketServer[I, O] {
+	s := &WebSocketServer[I, O]{
+		h:                   h,
+		dec:                 dec,
+		enc:                 enc,
+		errorEncoder:        DefaultErrorEncoder,
+		messageErrorEncoder: DefaultMessageErrorEncoder,
+		errorHandler:        ErrorHandlerFunc(LogErrorHandler),
+		checkOrigin:         sameOrigin,
+		readLimit:           DefaultWebSocketReadLimit,
+		conns:               newWSConns(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s WebSocketServer[I, O]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hijacker, canHijack := w.(http.Hijacker)
+
+	var conn *wsConn
+	if len(s.finalizer) > 0 {
+		iw := &interceptingWriter{ResponseWriter: w, code: http.StatusOK}
+		items := new(int64)
+		ctx = context.WithValue(ctx, responseItemsKey{}, items)
+		defer func() {
+			headers, written := iw.Header(), iw.written
+			if conn != nil {
+				iw.code, headers, written = http.StatusSwitchingProtocols, conn.header, conn.bytesWritten()
+			}
+			ctx = context.WithValue(ctx, ContextKeyResponseHeaders, headers)
+			ctx = context.WithValue(ctx, ContextKeyResponseSize, written)
+			ctx = context.WithValue(ctx, ContextKeyResponseItems, atomic.LoadInt64(items))
+			for _, f := range s.finalizer {
+				f(ctx, iw.code, r)
+			}
+		}()
+		w = iw
+	}
+
+	for _, f := range s.before {
+		ctx = f(ctx, r)
+	}
+
+	err := checkHandshake(r, s.checkOrigin)
+	if err == nil && s.conns.isShutdown() {
+		err = &HandshakeError{Code: http.StatusServiceUnavailable, Reason: "server is shutting down"}
+	}
+	if err == nil && !canHijack {
+		err = errors.New("websocket: response does not implement http.Hijacker")
+	}
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.errorEncoder(ctx, err, w)
+		return
+	}
+
+	conn, err = upgrade(hijacker, r)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		return
+	}
+	defer conn.Close()
+
+	if !s.conns.add(conn) {
+		conn.writeClose(CloseGoingAway, "")
+		return
+	}
+	defer s.conns.remove(conn)
+
+	conn.readLimit = s.readLimit
+	conn.readTimeout = 2 * s.pingInterval
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.keepalive(ctx, conn, done)
+
+	s.serve(ctx, conn)
+}
+
+// serve handles the messages until the connection is closed.
+func (s WebSocketServer[I, O]) serve(ctx context.Context, conn *wsConn) {
+	for {
+		op, data, err := conn.readMessage()
+		if err != nil {
+			var closeErr *CloseError
+			if stderrors.As(err, &closeErr) {
+				conn.writeClose(closeErr.Code, closeErr.Reason)
+			}
+			if err != io.EOF && !stderrors.Is(err, net.ErrClosed) && !conn.closing() {
+				s.errorHandler.Handle(ctx, err)
+			}
+			return
+		}
+
+		if op == opClose {
+			// echo the status code to complete the closing handshake
+			code := CloseNoStatus
+			if len(data) >= 2 {
+				code = int(data[0])<<8 | int(data[1])
+			}
+			conn.writeClose(code, "")
+			return
+		}
+
+		reply := s.handle(ctx, data)
+		if reply == nil {
+			continue
+		}
+		if err := conn.writeFrame(op, reply); err != nil {
+			if !conn.closing() {
+				s.errorHandler.Handle(ctx, err)
+			}
+			return
+		}
+		addResponseItems(ctx, 1)
+	}
+}
+
+// handle decodes the message, invokes the handler and encodes the reply, or
+// the error found along the way.
+func (s WebSocketServer[I, O]) handle(ctx context.Context, data []byte) []byte {
+	req, err := s.dec(ctx, data)
+	if err == nil {
+		var resp O
+		resp, err = s.h(ctx, req)
+		if err == nil {
+			var reply []byte
+			reply, err = s.enc(ctx, resp)
+			if err == nil {
+				return reply
+			}
+		}
+	}
+
+	s.errorHandler.Handle(ctx, err)
+	return s.messageErrorEncoder(ctx, err)
+}
+
+// keepalive sends the pings, and closes the connection with
+// CloseGoingAway when the context is done or the server shuts down.
+func (s WebSocketServer[I, O]) keepalive(ctx context.Context, conn *wsConn, done <-chan struct{}) {
+	var tick <-chan time.Time
+	if s.pingInterval > 0 {
+		ticker := time.NewTicker(s.pingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			conn.writeClose(CloseGoingAway, "")
+			return
+		case <-s.conns.closing:
+			conn.writeClose(CloseGoingAway, "")
+			return
+		case <-tick:
+			if err := conn.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Shutdown gracefully closes the connections: new handshakes are refused
+// with 503 Service Unavailable, and a close frame with CloseGoingAway is sent
+// on every connection. Then it waits until the peers complete the closing
+// handshake and the connections are released. If ctx is done first, the
+// remaining connections are dropped and the error of ctx is returned. Its
+// signature matches serverutil.ShutdownHook, use it with
+// http.Server.RegisterOnShutdown as:
+//
+//	srv.RegisterOnShutdown(func() { ws.Shutdown(context.Background()) })
+func (s WebSocketServer[I, O]) Shutdown(ctx context.Context) error {
+	return s.conns.shutdown(ctx)
+}
+
+// wsConns tracks the live connections of a WebSocketServer.
+type wsConns struct {
+	mu      sync.Mutex
+	conns   map[*wsConn]struct{}
+	wg      sync.WaitGroup
+	closing chan struct{} // closed on shutdown
+	closed  bool
+}
+
+func newWSConns() *wsConns {
+	return &wsConns{
+		conns:   make(map[*wsConn]struct{}),
+		closing: make(chan struct{}),
+	}
+}
+
+func (c *wsConns) isShutdown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// add tracks conn, it reports false once the server is shutting down.
+func (c *wsConns) add(conn *wsConn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+	return true
+}
+
+func (c *wsConns) remove(conn *wsConn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+func (c *wsConns) shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.closing)
+	}
+	c.mu.Unlock()
+
+	released := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		for conn := range c.conns {
+			conn.Close()
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// HandshakeError is returned when the request can not be upgraded to the
+// WebSocket protocol.
+type HandshakeError struct {
+	Code   int
+	Reason string
+}
+
+var _ StatusCoder = (*HandshakeError)(nil)
+
+// Error implements the error interface.
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Reason
+}
+
+// StatusCode returns the status code of the rejection, 400, 403 or 426.
+func (e *HandshakeError) StatusCode() int {
+	return e.Code
+}
+
+// Headers announces the supported version when it didn't match.
+func (e *HandshakeError) Headers() http.Header {
+	if e.Code != http.StatusUpgradeRequired {
+		return nil
+	}
+	return http.Header{"Sec-Websocket-Version": {"13"}}
+}
+
+func checkHandshake(r *http.Request, checkOrigin func(*http.Request) bool) error {
+	switch {
+	case r.Method != http.MethodGet:
+		return &HandshakeError{Code: http.StatusBadRequest, Reason: "method must be GET"}
+	case !headerHasToken(r.Header, "Connection", "upgrade"):
+		return &HandshakeError{Code: http.StatusBadRequest, Reason: "missing Connection: upgrade header"}
+	case !headerHasToken(r.Header, "Upgrade", "websocket"):
+		return &HandshakeError{Code: http.StatusBadRequest, Reason: "missing Upgrade: websocket header"}
+	case r.Header.Get("Sec-Websocket-Version") != "13":
+		return &HandshakeError{Code: http.StatusUpgradeRequired, Reason: "unsupported version"}
+	case !validKey(r.Header.Get("Sec-Websocket-Key")):
+		return &HandshakeError{Code: http.StatusBadRequest, Reason: "invalid Sec-WebSocket-Key header"}
+	case !checkOrigin(r):
+		return &HandshakeError{Code: http.StatusForbidden, Reason: "origin not allowed"}
+	}
+	return nil
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validKey(key string) bool {
+	b, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(b) == 16
+}
+
+// sameOrigin accepts the requests without Origin, or whose Origin matches the
+// Host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+websocketGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgrade hijacks the connection and completes the handshake.
+func upgrade(hijacker http.Hijacker, r *http.Request) (*wsConn, error) {
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack: %w", err)
+	}
+
+	header := http.Header{
+		"Upgrade":              {"websocket"},
+		"Connection":           {"Upgrade"},
+		"Sec-Websocket-Accept": {acceptKey(r.Header.Get("Sec-Websocket-Key"))},
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	// the deadlines set by the http.Server don't apply to hijacked connections
+	netConn.SetDeadline(time.Time{})
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %w", err)
+	}
+
+	return &wsConn{
+		conn:    netConn,
+		br:      brw.Reader,
+		header:  header,
+		written: int64(buf.Len()),
+	}, nil
+}
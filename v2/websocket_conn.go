@@ -0,0 +1,251 @@
+package kit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Close codes defined by RFC 6455.
+const (
+	CloseNormalClosure  = 1000
+	CloseGoingAway      = 1001
+	CloseProtocolError  = 1002
+	CloseNoStatus       = 1005
+	CloseInvalidPayload = 1007
+	CloseMessageTooBig  = 1009
+	CloseInternalError  = 1011
+)
+
+const (
+	// closeHandshakeTimeout is the time given to the peer to answer a close
+	// frame.
+	closeHandshakeTimeout = 5 * time.Second
+
+	// writeTimeout is the time allowed to write a frame.
+	writeTimeout = 10 * time.Second
+)
+
+// Frame opcodes defined by RFC 6455.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// CloseError is returned when the connection is closed because the peer
+// violated the protocol, the code is sent to the peer in the close frame.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+var errConnClosing = errors.New("websocket: connection is closing")
+
+// wsConn reads and writes the frames of a server side WebSocket connection.
+type wsConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	header      http.Header // of the handshake response
+	readLimit   int64       // always positive
+	readTimeout time.Duration
+
+	mu        sync.Mutex // guards the writes and the fields below
+	written   int64
+	closeSent bool
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wsConn) bytesWritten() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written
+}
+
+// closing reports whether a close frame was sent.
+func (c *wsConn) closing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeSent
+}
+
+// writeFrame writes a single, final, unmasked frame. No frame can be written
+// after a close frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeSent {
+		return errConnClosing
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|op)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127)
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(n))
+		frame = append(frame, size[:]...)
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	n, err := c.conn.Write(frame)
+	c.written += int64(n)
+	return err
+}
+
+// writeClose starts, or completes, the closing handshake. The peer has a
+// grace period to answer before the connection is dropped.
+func (c *wsConn) writeClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+	}
+	if err := c.writeFrame(opClose, payload); err != nil {
+		return err
+	}
+	return c.conn.SetReadDeadline(time.Now().Add(closeHandshakeTimeout))
+}
+
+// readMessage reads the next data message, joining its fragments, or the
+// payload of a close frame, with the opClose opcode. Pings are answered and
+// pongs are discarded.
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var (
+		op      byte
+		message []byte
+	)
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOp {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil && err != errConnClosing {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return opClose, payload, nil
+		case opText, opBinary:
+			if op != 0 {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "expected a continuation frame"}
+			}
+			op = frameOp
+		case opContinuation:
+			if op == 0 {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unexpected continuation frame"}
+			}
+		default:
+			return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unknown opcode"}
+		}
+
+		if int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if op == opText && !utf8.Valid(message) {
+				return 0, nil, &CloseError{Code: CloseInvalidPayload, Reason: "invalid UTF-8"}
+			}
+			return op, message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame, unmasking its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if c.readTimeout > 0 && !c.closing() {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size := int64(header[1] & 0x7f)
+
+	switch {
+	case header[0]&0x70 != 0:
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	case !masked:
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "client frames must be masked"}
+	case op >= opClose && (!fin || size > 125):
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid payload length"}
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if size > c.readLimit {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, op, payload, nil
+}
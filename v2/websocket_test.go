@@ -0,0 +1,326 @@
+package kit_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	kit "github.com/mishudark/kit/v2"
+)
+
+// wsClient is a minimal WebSocket client, enough to exercise the server.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server) *wsClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	req.Header.Set("Authorization", "Bearer secret")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusSwitchingProtocols, resp.StatusCode; want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+
+	return &wsClient{conn: conn, br: br}
+}
+
+func (c *wsClient) write(t *testing.T, op byte, payload []byte) {
+	t.Helper()
+
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestWebSocketServer(t *testing.T) {
+	type authKey struct{}
+
+	var (
+		finalCode  int
+		finalItems int64
+		finalized  = make(chan struct{})
+	)
+	handler := kit.NewWebSocketServer(
+		func(ctx context.Context, req testRequest) (testResponse, error) {
+			token, _ := ctx.Value(authKey{}).(string)
+			return testResponse{Greeting: "hello " + req.Name + " " + token}, nil
+		},
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketBefore[testRequest, testResponse](func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, authKey{}, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		}),
+		kit.WebSocketErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+		kit.WebSocketFinalizer[testRequest, testResponse](func(ctx context.Context, code int, _ *http.Request) {
+			finalCode = code
+			finalItems, _ = ctx.Value(kit.ContextKeyResponseItems).(int64)
+			close(finalized)
+		}),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv)
+	defer c.conn.Close()
+
+	tc := [...]struct {
+		send []byte
+		op   byte
+		want string
+	}{
+		{send: []byte(`{"name":"john"}`), op: 0x1, want: `"greeting":"hello john secret"`},
+		{send: []byte(`{"name":`), op: 0x1, want: `"error":"can not unmarshal message"`},
+		{send: nil, op: 0x9, want: ""},
+	}
+	for _, tt := range tc {
+		c.write(t, tt.op, tt.send)
+		op, payload := c.read(t)
+		if tt.op == 0x9 {
+			if want, have := byte(0xa), op; want != have {
+				t.Errorf("want pong, have opcode %d", have)
+			}
+			continue
+		}
+		if want, have := tt.want, string(payload); !strings.Contains(have, want) {
+			t.Errorf("want %s in %s", want, have)
+		}
+	}
+
+	closePayload := make([]byte, 2)
+	binary.BigEndian.PutUint16(closePayload, kit.CloseNormalClosure)
+	c.write(t, 0x8, closePayload)
+	op, payload := c.read(t)
+	if want, have := byte(0x8), op; want != have {
+		t.Fatalf("want close, have opcode %d", have)
+	}
+	if want, have := kit.CloseNormalClosure, int(binary.BigEndian.Uint16(payload)); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	<-finalized
+	if want, have := http.StatusSwitchingProtocols, finalCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := int64(2), finalItems; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestWebSocketServerPing(t *testing.T) {
+	handler := kit.NewWebSocketServer(
+		greet,
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketPingInterval[testRequest, testResponse](10*time.Millisecond),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv)
+	defer c.conn.Close()
+
+	if op, _ := c.read(t); op != 0x9 {
+		t.Errorf("want ping, have opcode %d", op)
+	}
+}
+
+func TestWebSocketServerHandshake(t *testing.T) {
+	handler := kit.NewWebSocketServer(
+		greet,
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+	)
+
+	tc := [...]struct {
+		name    string
+		version string
+		origin  string
+		want    int
+	}{
+		{name: "plain request", want: http.StatusBadRequest},
+		{name: "unsupported version", version: "8", want: http.StatusUpgradeRequired},
+		{name: "cross origin", version: "13", origin: "http://evil.example", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.version != "" {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Sec-WebSocket-Version", tt.version)
+				req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if want, have := tt.want, rec.Code; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestWebSocketServerShutdown(t *testing.T) {
+	handler := kit.NewWebSocketServer(
+		greet,
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv)
+	defer c.conn.Close()
+
+	// make sure the connection is being served before shutting down
+	c.write(t, 0x1, []byte(`{"name":"john"}`))
+	c.read(t)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- handler.Shutdown(context.Background()) }()
+
+	op, payload := c.read(t)
+	if want, have := byte(0x8), op; want != have {
+		t.Fatalf("want close, have opcode %d", have)
+	}
+	if want, have := kit.CloseGoingAway, int(binary.BigEndian.Uint16(payload)); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	c.write(t, 0x8, payload)
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("want nil, have %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once the connection was closed")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if want, have := http.StatusServiceUnavailable, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestWebSocketServerShutdownTimeout(t *testing.T) {
+	handler := kit.NewWebSocketServer(
+		greet,
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv)
+	defer c.conn.Close()
+	c.write(t, 0x1, []byte(`{"name":"john"}`))
+	c.read(t)
+
+	// the peer never answers the close frame
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, handler.Shutdown(ctx); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestWebSocketServerReadLimit(t *testing.T) {
+	handler := kit.NewWebSocketServer(
+		greet,
+		kit.DecodeJSONMessage[testRequest],
+		kit.EncodeJSONMessage[testResponse],
+		kit.WebSocketReadLimit[testRequest, testResponse](0),
+		kit.WebSocketErrorHandler[testRequest, testResponse](kit.ErrorHandlerFunc(func(context.Context, error) {})),
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv)
+	defer c.conn.Close()
+
+	// a frame announcing a 1 TB payload
+	frame := make([]byte, 10)
+	frame[0], frame[1] = 0x82, 0x80|127
+	binary.BigEndian.PutUint64(frame[2:], 1<<40)
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	op, payload := c.read(t)
+	if want, have := byte(0x8), op; want != have {
+		t.Fatalf("want close, have opcode %d", have)
+	}
+	if want, have := kit.CloseMessageTooBig, int(binary.BigEndian.Uint16(payload)); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}